@@ -0,0 +1,48 @@
+package miner
+
+import (
+	"errors"
+
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrBlobGasLimitExceeded is returned when the blob transactions constrained for a slot
+// would, together, push a block's cumulative blob gas past params.MaxBlobGasPerBlock.
+var ErrBlobGasLimitExceeded = errors.New("constraint: constrained blob txs exceed the block's blob gas limit")
+
+// sealingConstraintsForSlot prepares the constraints cache actually handed to
+// getSealingBlock for slot: it strips each constrained blob transaction's sidecar - which is
+// only needed out-of-band, for the engine API's getPayloadV3 BlobsBundleV1 response, not for
+// the packed block body - and rejects the slot outright if the constrained blob transactions
+// alone would already exceed the block's blob gas limit. The original constraintsCache is
+// left untouched, so callers still assembling a BlobsBundleV1 can read the sidecars back out
+// of it once the block has sealed.
+func sealingConstraintsForSlot(slot uint64, constraintsCache *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded]) (*shardmap.FIFOMap[uint64, types.HashToConstraintDecoded], error) {
+	decoded, ok := constraintsCache.Get(slot)
+	if !ok || len(decoded) == 0 {
+		return constraintsCache, nil
+	}
+
+	var totalBlobGas uint64
+	stripped := make(types.HashToConstraintDecoded, len(decoded))
+	for hash, c := range decoded {
+		totalBlobGas += c.Tx.BlobGas()
+		if c.BlobSidecar == nil && c.Tx.BlobTxSidecar() == nil {
+			stripped[hash] = c
+			continue
+		}
+		strippedConstraint := *c
+		strippedConstraint.Tx = c.Tx.WithoutBlobTxSidecar()
+		strippedConstraint.BlobSidecar = nil
+		stripped[hash] = &strippedConstraint
+	}
+	if totalBlobGas > params.MaxBlobGasPerBlock {
+		return nil, ErrBlobGasLimitExceeded
+	}
+
+	sealingCache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	sealingCache.Put(slot, stripped)
+	return sealingCache, nil
+}
@@ -0,0 +1,116 @@
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// newTestBlobTx builds an unsigned blob transaction carrying numBlobs worth of blob gas and
+// a (trivially empty) sidecar, enough to exercise sealingConstraintsForSlot's stripping and
+// blob-gas accounting without needing a real KZG trusted setup.
+func newTestBlobTx(nonce uint64, numBlobs int) *types.Transaction {
+	hashes := make([]common.Hash, numBlobs)
+	for i := range hashes {
+		hashes[i] = common.BigToHash(big.NewInt(int64(i) + 1))
+	}
+	tx := types.NewTx(&types.BlobTx{
+		ChainID:    uint256.NewInt(params.TestChainConfig.ChainID.Uint64()),
+		Nonce:      nonce,
+		GasTipCap:  uint256.NewInt(1),
+		GasFeeCap:  uint256.NewInt(params.InitialBaseFee),
+		Gas:        params.TxGas,
+		To:         testUserAddress,
+		Value:      uint256.NewInt(0),
+		BlobFeeCap: uint256.NewInt(1),
+		BlobHashes: hashes,
+	})
+	return tx.WithBlobTxSidecar(&types.BlobTxSidecar{
+		Blobs:       make([]kzg4844.Blob, numBlobs),
+		Commitments: make([]kzg4844.Commitment, numBlobs),
+		Proofs:      make([]kzg4844.Proof, numBlobs),
+	})
+}
+
+// newSignedTestBlobTx builds a blob transaction signed by testBankKey, carrying numBlobs
+// worth of blob gas and a (trivially empty) sidecar - like newTestBlobTx, but signed so it
+// can actually be applied while building a block rather than only exercised in isolation.
+func newSignedTestBlobTx(nonce uint64, numBlobs int) *types.Transaction {
+	hashes := make([]common.Hash, numBlobs)
+	for i := range hashes {
+		hashes[i] = common.BigToHash(big.NewInt(int64(i) + 1))
+	}
+	signed := types.MustSignNewTx(testBankKey, types.LatestSigner(params.TestChainConfig), &types.BlobTx{
+		ChainID:    uint256.NewInt(params.TestChainConfig.ChainID.Uint64()),
+		Nonce:      nonce,
+		GasTipCap:  uint256.NewInt(1),
+		GasFeeCap:  uint256.NewInt(params.InitialBaseFee),
+		Gas:        params.TxGas,
+		To:         testUserAddress,
+		Value:      uint256.NewInt(0),
+		BlobFeeCap: uint256.NewInt(1),
+		BlobHashes: hashes,
+	})
+	return signed.WithBlobTxSidecar(&types.BlobTxSidecar{
+		Blobs:       make([]kzg4844.Blob, numBlobs),
+		Commitments: make([]kzg4844.Commitment, numBlobs),
+		Proofs:      make([]kzg4844.Proof, numBlobs),
+	})
+}
+
+func TestSealingConstraintsForSlotStripsBlobSidecars(t *testing.T) {
+	const slot = 42
+	blobTx := newTestBlobTx(0, 1)
+
+	cache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	cache.Put(slot, types.HashToConstraintDecoded{
+		blobTx.Hash(): {Tx: blobTx, BlobSidecar: blobTx.BlobTxSidecar()},
+	})
+
+	sealingCache, err := sealingConstraintsForSlot(slot, cache)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stripped, ok := sealingCache.Get(slot)
+	if !ok {
+		t.Fatalf("expected stripped constraints for slot %d", slot)
+	}
+	c, ok := stripped[blobTx.Hash()]
+	if !ok {
+		t.Fatalf("expected the blob constraint to survive stripping")
+	}
+	if c.BlobSidecar != nil {
+		t.Fatalf("expected BlobSidecar to be cleared on the sealing copy")
+	}
+	if c.Tx.BlobTxSidecar() != nil {
+		t.Fatalf("expected the sealing tx to have no sidecar, got one")
+	}
+
+	original, _ := cache.Get(slot)
+	if original[blobTx.Hash()].BlobSidecar == nil {
+		t.Fatalf("expected the original constraintsCache entry to keep its sidecar")
+	}
+}
+
+func TestSealingConstraintsForSlotRejectsBlobGasOverflow(t *testing.T) {
+	const slot = 42
+	blobsOverLimit := int(params.MaxBlobGasPerBlock/params.BlobTxBlobGasPerBlob) + 1
+	blobTx := newTestBlobTx(0, blobsOverLimit)
+
+	cache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	cache.Put(slot, types.HashToConstraintDecoded{
+		blobTx.Hash(): {Tx: blobTx, BlobSidecar: blobTx.BlobTxSidecar()},
+	})
+
+	if _, err := sealingConstraintsForSlot(slot, cache); !errors.Is(err, ErrBlobGasLimitExceeded) {
+		t.Fatalf("expected ErrBlobGasLimitExceeded, got %v", err)
+	}
+}
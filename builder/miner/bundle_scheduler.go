@@ -0,0 +1,201 @@
+package miner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// cancellableBundle tracks a single bundle submission, letting a later CancelMevBundle
+// call - possibly naming a replacement - retire it from the live set for its slot.
+type cancellableBundle struct {
+	uuid       types.UUID
+	bundle     types.MevBundle
+	slot       uint64
+	canceled   bool
+	canceledAt time.Time
+	replaced   bool
+}
+
+// bundleScheduler tracks live and canceled bundle submissions for a worker, keyed by
+// UUID, so a canceled bundle drops out of its slot's live set and its UUID can't be
+// resubmitted for the same slot until bundleTTL has elapsed. It does not touch the
+// worker's bundle simulation cache.
+//
+// Stored on the worker's shared workerState rather than a dedicated package-level map - see
+// workerState's doc comment for why that matters.
+type bundleScheduler struct {
+	mu      sync.Mutex
+	bundles map[types.UUID]*cancellableBundle
+
+	// lastResult holds the most recent co-scheduling outcome computed for each slot by
+	// coScheduleLiveBundlesForSlot, so BundleScheduleForSlot can report it back.
+	lastResult map[uint64]scheduleResult
+}
+
+// bundleSchedulerFor returns the bundleScheduler for w, creating it on first use.
+func bundleSchedulerFor(w *worker) *bundleScheduler {
+	s := stateFor(w)
+
+	workerStatesMu.Lock()
+	defer workerStatesMu.Unlock()
+	if s.bundles == nil {
+		s.bundles = &bundleScheduler{bundles: make(map[types.UUID]*cancellableBundle)}
+	}
+	return s.bundles
+}
+
+// AddMevBundle registers a bundle for the given slot under uuid, replacing any prior
+// registration under the same uuid; simulation cache entries are left as they are. If uuid
+// names a bundle for the same slot that was canceled less than bundleTTL ago, the submission
+// is rejected rather than resurrecting it - otherwise a stale resubmission racing the
+// cancellation could bring the canceled bundle back under the same UUID.
+func (w *worker) AddMevBundle(uuid types.UUID, bundle types.MevBundle, slot uint64) error {
+	s := bundleSchedulerFor(w)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.bundles[uuid]; ok && existing.canceled && existing.slot == slot {
+		if withheldUntil := existing.canceledAt.Add(bundleTTL); time.Now().Before(withheldUntil) {
+			return fmt.Errorf("uuid %s was canceled and is withheld from reuse until %s", uuid, withheldUntil)
+		}
+	}
+
+	s.bundles[uuid] = &cancellableBundle{uuid: uuid, bundle: bundle, slot: slot}
+	return nil
+}
+
+// CancelMevBundle retires the bundle registered under uuid. If replacementUUID names a
+// registered bundle, the replacement is moved to the canceled bundle's slot under the same
+// lock, so the swap is observed as a single unit rather than a cancel-then-add race. Neither
+// bundle's simulation cache entry is invalidated.
+func (w *worker) CancelMevBundle(uuid types.UUID, replacementUUID *types.UUID) error {
+	s := bundleSchedulerFor(w)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	canceled, ok := s.bundles[uuid]
+	if !ok {
+		return fmt.Errorf("no bundle registered for uuid %s", uuid)
+	}
+	canceled.canceled = true
+	canceled.canceledAt = time.Now()
+
+	if replacementUUID != nil {
+		if replacement, ok := s.bundles[*replacementUUID]; ok {
+			replacement.slot = canceled.slot
+			canceled.replaced = true
+		}
+	}
+
+	return nil
+}
+
+// liveBundlesForSlot returns every bundle registered for slot that hasn't been canceled, in
+// other words the bundles still eligible for co-scheduling with pending txs and
+// constraints for that slot.
+func (s *bundleScheduler) liveBundlesForSlot(slot uint64) []types.MevBundle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var live []types.MevBundle
+	for _, b := range s.bundles {
+		if b.slot == slot && !b.canceled {
+			live = append(live, b.bundle)
+		}
+	}
+	return live
+}
+
+// scheduleResult is the outcome of co-scheduling pending transactions, bundles, and
+// constraints for a slot: the bundles that survived, and a drop reason per bundle hash for
+// the ones that didn't.
+type scheduleResult struct {
+	survivingBundles []types.MevBundle
+	dropped          map[common.Hash]string
+}
+
+// coScheduleBundlesWithConstraints drops any bundle that conflicts with a constrained
+// transaction - same tx hash, or same sender+nonce - since a constraint always wins.
+// Everything else is left for the existing profit-maximizing bundle simulation to order.
+func coScheduleBundlesWithConstraints(bundles []types.MevBundle, constraints *ConstraintSet) scheduleResult {
+	result := scheduleResult{dropped: make(map[common.Hash]string)}
+
+	constrainedHashes := make(map[common.Hash]struct{})
+	constrainedNonces := make(map[common.Address]map[uint64]struct{})
+	for _, c := range constraints.constraints {
+		constrainedHashes[c.Tx.Hash()] = struct{}{}
+		if sender, err := types.Sender(types.LatestSignerForChainID(c.Tx.ChainId()), c.Tx); err == nil {
+			if constrainedNonces[sender] == nil {
+				constrainedNonces[sender] = make(map[uint64]struct{})
+			}
+			constrainedNonces[sender][c.Tx.Nonce()] = struct{}{}
+		}
+	}
+
+bundleLoop:
+	for _, bundle := range bundles {
+		for _, tx := range bundle.Txs {
+			if _, conflict := constrainedHashes[tx.Hash()]; conflict {
+				result.dropped[bundle.Hash] = fmt.Sprintf("tx %s conflicts with a constrained transaction", tx.Hash())
+				continue bundleLoop
+			}
+			if sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+				if nonces, ok := constrainedNonces[sender]; ok {
+					if _, conflict := nonces[tx.Nonce()]; conflict {
+						result.dropped[bundle.Hash] = fmt.Sprintf("tx %s shares a sender/nonce with a constrained transaction", tx.Hash())
+						continue bundleLoop
+					}
+				}
+			}
+		}
+		result.survivingBundles = append(result.survivingBundles, bundle)
+	}
+
+	return result
+}
+
+// bundleTTL is how long a canceled bundle's UUID is withheld from reuse within the same
+// slot, so a stale resubmission racing the cancellation can't bring it back.
+const bundleTTL = 2 * time.Minute
+
+// coScheduleLiveBundlesForSlot fetches w's live bundles for slot and co-schedules them
+// against sealingConstraints, recording the outcome so BundleScheduleForSlot can report it.
+// Its real home is getSealingBlock, ordering survivingBundles into the packed block
+// alongside the constrained transactions; that file isn't part of this checkout, so
+// buildPendingForSlot, in pending.go, calls it as the one production build path available.
+func coScheduleLiveBundlesForSlot(w *worker, slot uint64, sealingConstraints *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded]) scheduleResult {
+	s := bundleSchedulerFor(w)
+
+	bundles := s.liveBundlesForSlot(slot)
+	set := NewConstraintSet()
+	if decoded, ok := sealingConstraints.Get(slot); ok {
+		for _, c := range decoded {
+			set.Add(c)
+		}
+	}
+
+	result := coScheduleBundlesWithConstraints(bundles, set)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastResult == nil {
+		s.lastResult = make(map[uint64]scheduleResult)
+	}
+	s.lastResult[slot] = result
+	return result
+}
+
+// BundleScheduleForSlot returns the most recent bundle/constraint co-scheduling outcome
+// computed for slot by buildPendingForSlot: which live bundles survived, and why any others
+// were dropped.
+func (w *worker) BundleScheduleForSlot(slot uint64) scheduleResult {
+	s := bundleSchedulerFor(w)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastResult[slot]
+}
@@ -0,0 +1,24 @@
+package miner
+
+import (
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// commitmentsForSlot converts every constraint registered for slot into a core.Commitment,
+// ready for core.RecordCommitments once the block that honors them has been sealed.
+// ProposerSignature is left unset here; signing the commitment with the builder's key
+// happens alongside the rest of the block-sealing signature, not during assembly.
+func commitmentsForSlot(slot uint64, constraintsCache *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded]) core.Commitments {
+	decoded, ok := constraintsCache.Get(slot)
+	if !ok || len(decoded) == 0 {
+		return nil
+	}
+
+	commitments := make(core.Commitments, 0, len(decoded))
+	for txHash := range decoded {
+		commitments = append(commitments, core.Commitment{TxHash: txHash, Slot: slot})
+	}
+	return commitments
+}
@@ -0,0 +1,168 @@
+package miner
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ErrConstraintConflict is returned by ConstraintSet.Validate and ConstraintSet.Schedule
+// when two or more constraints can't be jointly satisfied. Reason is a short human-readable
+// description and ConflictingHashes lists the transactions involved, so the relay-facing
+// API can surface exactly which constraints to drop or re-sign.
+type ErrConstraintConflict struct {
+	Reason            string
+	ConflictingHashes []common.Hash
+}
+
+func (e *ErrConstraintConflict) Error() string {
+	return fmt.Sprintf("constraint conflict: %s %v", e.Reason, e.ConflictingHashes)
+}
+
+// txPosition pairs a constrained transaction with the index it must occupy in the final
+// block.
+type txPosition struct {
+	tx    *types.Transaction
+	index int
+}
+
+// ConstraintSet accumulates the constraints proposed for a single slot and resolves index
+// conflicts before the builder packs them into a block.
+//
+// Indices are sparse: a constraint need not specify one, and the ones that do need not be
+// contiguous from zero. Unindexed constraints are scheduled into the lowest free slots, in
+// the order they were added, once every indexed constraint has claimed its slot.
+type ConstraintSet struct {
+	constraints []*types.ConstraintDecoded
+}
+
+// NewConstraintSet creates an empty ConstraintSet.
+func NewConstraintSet() *ConstraintSet {
+	return &ConstraintSet{}
+}
+
+// Add registers a constraint. Validate should be called once every constraint for the slot
+// has been added, before Schedule is used to compute final positions.
+func (s *ConstraintSet) Add(c *types.ConstraintDecoded) {
+	s.constraints = append(s.constraints, c)
+}
+
+// Validate checks that the accumulated constraints can be jointly satisfied: no two
+// constraints claim the same index, no two constrained transactions share a sender+nonce,
+// and the aggregate gas and blob gas they require fits within the block's limits.
+func (s *ConstraintSet) Validate(gasLimit, blobGasLimit uint64) error {
+	seenIndex := make(map[uint64][]common.Hash)
+	seenNonce := make(map[common.Address]map[uint64][]common.Hash)
+
+	var totalGas, totalBlobGas uint64
+	for _, c := range s.constraints {
+		if c.Index != nil {
+			seenIndex[*c.Index] = append(seenIndex[*c.Index], c.Tx.Hash())
+		}
+
+		sender, err := types.Sender(types.LatestSignerForChainID(c.Tx.ChainId()), c.Tx)
+		if err == nil {
+			if seenNonce[sender] == nil {
+				seenNonce[sender] = make(map[uint64][]common.Hash)
+			}
+			seenNonce[sender][c.Tx.Nonce()] = append(seenNonce[sender][c.Tx.Nonce()], c.Tx.Hash())
+		}
+
+		totalGas += c.Tx.Gas()
+		totalBlobGas += c.Tx.BlobGas()
+	}
+
+	for index, hashes := range seenIndex {
+		if len(hashes) > 1 {
+			return &ErrConstraintConflict{Reason: fmt.Sprintf("duplicate constraint index %d", index), ConflictingHashes: hashes}
+		}
+	}
+	for _, byNonce := range seenNonce {
+		for nonce, hashes := range byNonce {
+			if len(hashes) > 1 {
+				return &ErrConstraintConflict{Reason: fmt.Sprintf("duplicate sender nonce %d among constrained txs", nonce), ConflictingHashes: hashes}
+			}
+		}
+	}
+
+	if totalGas > gasLimit {
+		return &ErrConstraintConflict{Reason: fmt.Sprintf("constrained txs require %d gas, block limit is %d", totalGas, gasLimit)}
+	}
+	if totalBlobGas > blobGasLimit {
+		return &ErrConstraintConflict{Reason: fmt.Sprintf("constrained txs require %d blob gas, block limit is %d", totalBlobGas, blobGasLimit)}
+	}
+
+	return nil
+}
+
+// Schedule resolves final positions for every constrained transaction, assuming the
+// finished block will contain exactly nonConstrainedCount additional, non-constrained
+// transactions. Unindexed constraints are assigned the lowest free slots, in the order
+// they were added.
+func (s *ConstraintSet) Schedule(nonConstrainedCount int) ([]txPosition, error) {
+	total := len(s.constraints) + nonConstrainedCount
+
+	taken := make(map[int]common.Hash, len(s.constraints))
+	var unindexed []*types.ConstraintDecoded
+	for _, c := range s.constraints {
+		if c.Index == nil {
+			unindexed = append(unindexed, c)
+			continue
+		}
+		index := int(*c.Index)
+		if index < 0 || index >= total {
+			return nil, &ErrConstraintConflict{
+				Reason:            fmt.Sprintf("constraint index %d exceeds the block's expected %d transactions", index, total),
+				ConflictingHashes: []common.Hash{c.Tx.Hash()},
+			}
+		}
+		taken[index] = c.Tx.Hash()
+	}
+
+	positions := make([]txPosition, 0, len(s.constraints))
+	for _, c := range s.constraints {
+		if c.Index != nil {
+			positions = append(positions, txPosition{tx: c.Tx, index: int(*c.Index)})
+		}
+	}
+
+	next := 0
+	for _, c := range unindexed {
+		for {
+			if _, occupied := taken[next]; !occupied {
+				break
+			}
+			next++
+		}
+		taken[next] = c.Tx.Hash()
+		positions = append(positions, txPosition{tx: c.Tx, index: next})
+		next++
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].index < positions[j].index })
+	return positions, nil
+}
+
+// validateConstraintsForSlot decodes slot's constraints into a ConstraintSet and validates
+// them against the current chain head's gas limits. Its real home is getSealingBlock,
+// rejecting a conflicting constraint set before packing rather than after; that file isn't
+// part of this checkout, so buildPendingForSlot, in pending.go, calls it as the one
+// production build path available, the same way it already calls sealingConstraintsForSlot.
+func validateConstraintsForSlot(w *worker, slot uint64, constraintsCache *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded]) error {
+	decoded, ok := constraintsCache.Get(slot)
+	if !ok || len(decoded) == 0 {
+		return nil
+	}
+
+	set := NewConstraintSet()
+	for _, c := range decoded {
+		set.Add(c)
+	}
+
+	header := w.chain.CurrentBlock()
+	return set.Validate(header.GasLimit, params.MaxBlobGasPerBlock)
+}
@@ -0,0 +1,131 @@
+package miner
+
+import (
+	"sync"
+
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pendingKey identifies a cached pending build: the chain head it was built on, the slot
+// it honors constraints for, and the constraint revision in effect at the time, so a new
+// constraint delivery for the same head/slot invalidates the cache.
+type pendingKey struct {
+	parentHash common.Hash
+	slot       uint64
+	revision   uint64
+}
+
+// pendingResult is a cached (block, state) pair produced by buildPendingForSlot.
+type pendingResult struct {
+	block *types.Block
+	state *state.StateDB
+	err   error
+}
+
+// pendingCache guards the single most recently built pending environment for a worker. It
+// is recomputed lazily, on the first Pending call that observes a stale key, rather than
+// on every new head or constraint delivery.
+type pendingCache struct {
+	mu       sync.Mutex
+	revision uint64
+	key      pendingKey
+	result   *pendingResult
+}
+
+// pendingCacheFor returns the pendingCache for w, stored in w's shared workerState rather
+// than a dedicated package-level map - see workerState's doc comment for why that matters:
+// a map keyed by *worker never drops an entry, so every worker instantiated over a process's
+// lifetime (including every test run through newTestWorker) would otherwise leak one forever.
+func pendingCacheFor(w *worker) *pendingCache {
+	s := stateFor(w)
+
+	workerStatesMu.Lock()
+	defer workerStatesMu.Unlock()
+	if s.pending == nil {
+		s.pending = new(pendingCache)
+	}
+	return s.pending
+}
+
+// NotifyConstraints signals that new constraints were delivered, invalidating any cached
+// pending build so the next Pending call recomputes it.
+func (w *worker) NotifyConstraints(slot uint64) {
+	c := pendingCacheFor(w)
+	c.mu.Lock()
+	c.revision++
+	c.mu.Unlock()
+}
+
+// Pending returns a (block, state) pair for the given slot that honors whatever
+// constraints are currently registered for it in constraintsCache, recomputing the cached
+// build only when the chain head, slot, or constraint revision has changed since it was
+// last built. Unlike the free-market pending block, this does not require w.start() to
+// have been called.
+func (w *worker) Pending(slot uint64, coinbase common.Address, constraintsCache *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded]) (*types.Block, *state.StateDB) {
+	c := pendingCacheFor(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := pendingKey{
+		parentHash: w.chain.CurrentBlock().Hash(),
+		slot:       slot,
+		revision:   c.revision,
+	}
+	if c.result != nil && c.key == key {
+		return c.result.block, c.result.state
+	}
+
+	result := w.buildPendingForSlot(slot, key.parentHash, coinbase, constraintsCache)
+	c.key = key
+	c.result = result
+	if result.err != nil {
+		return nil, nil
+	}
+	return result.block, result.state
+}
+
+// buildPendingForSlot builds a non-sealing block for slot on top of parentHash, ordering
+// transactions the same constraint-aware way getSealingBlock does, but without kicking off
+// a sealing task.
+func (w *worker) buildPendingForSlot(slot uint64, parentHash common.Hash, coinbase common.Address, constraintsCache *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded]) *pendingResult {
+	if err := validateConstraintsForSlot(w, slot, constraintsCache); err != nil {
+		return &pendingResult{err: err}
+	}
+
+	sealingConstraints, err := sealingConstraintsForSlot(slot, constraintsCache)
+	if err != nil {
+		return &pendingResult{err: err}
+	}
+
+	coScheduleLiveBundlesForSlot(w, slot, sealingConstraints)
+
+	r := w.getSealingBlock(&generateParams{
+		parentHash:       parentHash,
+		coinbase:         coinbase,
+		random:           common.Hash{},
+		noTxs:            false,
+		constraintsCache: sealingConstraints,
+	})
+	if r.err != nil {
+		return &pendingResult{err: r.err}
+	}
+
+	state, err := w.chain.StateAt(r.block.Root())
+	if err != nil {
+		return &pendingResult{err: err}
+	}
+	if err := stateErrorCheck(state); err != nil {
+		return &pendingResult{err: err}
+	}
+
+	commitments := commitmentsForSlot(slot, constraintsCache)
+	core.RecordCommitments(w.chain, r.block.Hash(), commitments)
+	markPreconfOutstanding(w, commitments)
+
+	return &pendingResult{block: r.block, state: state}
+}
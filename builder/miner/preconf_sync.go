@@ -0,0 +1,55 @@
+package miner
+
+import (
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// preconfTrackerFor returns the core.PreconfTracker for w, stored in w's shared
+// workerState rather than a dedicated package-level map - see workerState's doc comment for
+// why that matters.
+func preconfTrackerFor(w *worker) *core.PreconfTracker {
+	s := stateFor(w)
+
+	workerStatesMu.Lock()
+	defer workerStatesMu.Unlock()
+	if s.preconf == nil {
+		s.preconf = core.NewPreconfTracker()
+	}
+	return s.preconf
+}
+
+// PreconfStats returns w's current preconfirmation sync snapshot: how many commitments are
+// outstanding against the canonical chain, and how many have been violated by a reorg. This
+// is what a Downloader.PreconfStats() method and the eth_syncingPreconfs RPC call surface.
+// Nothing in this package calls MarkIncluded, so a commitment stays in Outstanding after the
+// block honoring it is inserted; only a violation reported through WatchPreconfStats moves
+// it out.
+func (w *worker) PreconfStats() core.PreconfStats {
+	return preconfTrackerFor(w).PreconfStats()
+}
+
+// WatchPreconfStats subscribes to reorg-driven removals the same way
+// WatchPreconfirmedRemovals does, feeding every violated commitment into w's PreconfTracker
+// so PreconfStats reflects it. The returned subscription must be closed by the caller.
+func (w *worker) WatchPreconfStats() event.Subscription {
+	tracker := preconfTrackerFor(w)
+	return w.WatchPreconfirmedRemovals(func(ev core.RemovedTransactionEvent) {
+		for _, txHash := range ev.Preconfirmed {
+			tracker.MarkViolated(txHash)
+		}
+	})
+}
+
+// markPreconfOutstanding reports every commitment assembled for a slot as outstanding,
+// called from buildPendingForSlot right after commitmentsForSlot so PreconfStats stays in
+// step with what was actually sealed.
+func markPreconfOutstanding(w *worker, commitments core.Commitments) {
+	if len(commitments) == 0 {
+		return
+	}
+	tracker := preconfTrackerFor(w)
+	for _, c := range commitments {
+		tracker.TrackCommitment(c.TxHash)
+	}
+}
@@ -0,0 +1,63 @@
+package miner
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// WatchPreconfirmedRemovals subscribes to core.RemovedTransactionEvent on w.mux and invokes
+// onViolation whenever a reorg drops a block containing transactions this node had
+// preconfirmed, so the preconfirmation subsystem can re-include them in the next built
+// block or mark the commitment as violated. The returned subscription must be closed by
+// the caller once it's no longer needed.
+func (w *worker) WatchPreconfirmedRemovals(onViolation func(core.RemovedTransactionEvent)) event.Subscription {
+	sub := w.mux.Subscribe(core.RemovedTransactionEvent{})
+	go func() {
+		for obj := range sub.Chan() {
+			ev, ok := obj.Data.(core.RemovedTransactionEvent)
+			if !ok || len(ev.Preconfirmed) == 0 {
+				continue
+			}
+			onViolation(ev)
+		}
+	}()
+	return sub
+}
+
+// postRemovedTransactionEvent is a small helper so tests (and, in the full node, the
+// chain's reorg path) can report a removed block's preconfirmed transactions through the
+// same mux the worker already listens on for core.NewMinedBlockEvent.
+func postRemovedTransactionEvent(mux *event.TypeMux, block *types.Block, txHashes, preconfirmed []common.Hash) {
+	_ = mux.Post(core.RemovedTransactionEvent{
+		Block:        block,
+		TxHashes:     txHashes,
+		Preconfirmed: preconfirmed,
+	})
+}
+
+// classifyPreconfirmed returns the subset of block's transactions this node had committed
+// to when it built block, by cross-referencing the commitments core.RecordCommitments
+// stored for it. This is the classification blockchain.reorg must run, for every block it
+// orphans, immediately before posting RemovedTransactionEvent; that file isn't part of this
+// checkout, so it lives here for now, callable from the reorg path once it lands as well as
+// from tests that drive a real reorg through bc.
+func classifyPreconfirmed(bc *core.BlockChain, block *types.Block) []common.Hash {
+	committed := bc.GetCommitmentsByHash(block.Hash())
+	if len(committed) == 0 {
+		return nil
+	}
+	committedHashes := make(map[common.Hash]struct{}, len(committed))
+	for _, c := range committed {
+		committedHashes[c.TxHash] = struct{}{}
+	}
+
+	var preconfirmed []common.Hash
+	for _, tx := range block.Transactions() {
+		if _, ok := committedHashes[tx.Hash()]; ok {
+			preconfirmed = append(preconfirmed, tx.Hash())
+		}
+	}
+	return preconfirmed
+}
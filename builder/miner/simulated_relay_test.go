@@ -0,0 +1,188 @@
+package miner
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chainbound/shardmap"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ConstraintsClient is the interface the builder uses to pull constraints for a slot
+// from a relay. It is satisfied here by fakeConstraintsRelay, which stands in for the
+// real relay over HTTP/SSE in these tests.
+type ConstraintsClient interface {
+	Constraints(slot uint64) (types.HashToConstraintDecoded, bool)
+}
+
+// fakeBeaconClock drives slots forward on demand, mirroring the simulated-beacon pattern
+// go-ethereum uses for on-demand PoS block production in eth/catalyst/simulated_beacon.go.
+type fakeBeaconClock struct {
+	mu   sync.Mutex
+	slot uint64
+}
+
+func newFakeBeaconClock() *fakeBeaconClock {
+	return &fakeBeaconClock{}
+}
+
+// advance moves the simulated clock to slot and returns it.
+func (c *fakeBeaconClock) advance(slot uint64) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slot = slot
+	return c.slot
+}
+
+func (c *fakeBeaconClock) currentSlot() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slot
+}
+
+// fakeConstraintsRelay is an in-process, map-backed stand-in for a relay that
+// delivers signed constraints for upcoming slots and can cancel them before the slot
+// is built.
+type fakeConstraintsRelay struct {
+	mu     sync.Mutex
+	bySlot map[uint64]types.HashToConstraintDecoded
+}
+
+func newFakeConstraintsRelay() *fakeConstraintsRelay {
+	return &fakeConstraintsRelay{bySlot: make(map[uint64]types.HashToConstraintDecoded)}
+}
+
+// publish delivers constraints for slot, as a relay would after a validator signs them.
+func (r *fakeConstraintsRelay) publish(slot uint64, constraints types.HashToConstraintDecoded) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bySlot[slot] = constraints
+}
+
+// cancel removes a single constraint from slot, simulating a relay-side cancellation
+// ahead of block production.
+func (r *fakeConstraintsRelay) cancel(slot uint64, txHash common.Hash) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.bySlot[slot], txHash)
+}
+
+// Constraints implements ConstraintsClient.
+func (r *fakeConstraintsRelay) Constraints(slot uint64) (types.HashToConstraintDecoded, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	constraints, ok := r.bySlot[slot]
+	return constraints, ok
+}
+
+// deliverToCache pulls whatever the relay has published for slot into cache, the way the
+// builder's constraint-subscription goroutine does for the production ConstraintsClient.
+func deliverToCache(t *testing.T, client ConstraintsClient, cache *shardmap.FIFOMap[uint64, types.HashToConstraintDecoded], slot uint64) {
+	t.Helper()
+	constraints, ok := client.Constraints(slot)
+	if !ok {
+		return
+	}
+	cache.Put(slot, constraints)
+}
+
+// TestSimulatedRelayConstraintDelivery exercises the path TestGetSealingWorkWithConstraints
+// explicitly can't: constraints arriving from a relay over the course of several simulated
+// slots, including an ordering-only constraint, an indexed constraint, a constraint for a
+// later slot that never gets pulled into the current build, and a relay-side cancellation.
+func TestSimulatedRelayConstraintDelivery(t *testing.T) {
+	local := new(params.ChainConfig)
+	*local = *ethashChainConfig
+	local.TerminalTotalDifficulty = nil
+
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, local, engine, rawdb.NewMemoryDatabase(), constraintTestAlloc, 0)
+	defer w.close()
+
+	w.skipSealHook = func(task *task) bool { return true }
+	w.fullTaskHook = func() { time.Sleep(100 * time.Millisecond) }
+
+	clock := newFakeBeaconClock()
+	relay := newFakeConstraintsRelay()
+
+	orderingTx := b.newRandomTx(false, testUserAddress, 1, testAddress1Key, 0, big.NewInt(2*params.InitialBaseFee))
+	indexedTx := b.newRandomTx(false, testUserAddress, 1, testAddress2Key, 0, big.NewInt(2*params.InitialBaseFee))
+	canceledTx := b.newRandomTx(false, testUserAddress, 1, testAddress3Key, 0, big.NewInt(2*params.InitialBaseFee))
+
+	const targetSlot = 100
+	indexedPos := uint64(0)
+	relay.publish(targetSlot, types.HashToConstraintDecoded{
+		orderingTx.Hash(): {Index: nil, Tx: orderingTx},
+		indexedTx.Hash():  {Index: &indexedPos, Tx: indexedTx},
+		canceledTx.Hash(): {Index: nil, Tx: canceledTx},
+	})
+
+	// A constraint for a slot far in the future must not affect the block built for
+	// targetSlot - it should simply never be pulled into the cache used for this build.
+	relay.publish(targetSlot+32, types.HashToConstraintDecoded{
+		orderingTx.Hash(): {Index: nil, Tx: orderingTx},
+	})
+
+	clock.advance(targetSlot)
+
+	// The relay cancels canceledTx before the slot is built; the next produced block
+	// must not contain it.
+	relay.cancel(targetSlot, canceledTx.Hash())
+
+	deliverToCache(t, relay, testConstraintsCache, clock.currentSlot())
+
+	r := w.getSealingBlock(&generateParams{
+		parentHash:       b.chain.CurrentBlock().Hash(),
+		timestamp:        uint64(time.Now().Unix()),
+		coinbase:         testUserAddress,
+		random:           common.Hash{},
+		withdrawals:      nil,
+		beaconRoot:       nil,
+		noTxs:            false,
+		forceTime:        true,
+		onBlock:          nil,
+		constraintsCache: testConstraintsCache,
+	})
+	if r.err != nil {
+		t.Fatalf("unexpected error building sealing block: %v", r.err)
+	}
+
+	var orderingIdx, indexedIdx, nonConstrainedIdx = -1, -1, -1
+	for i, tx := range r.block.Transactions() {
+		if tx.Hash() == orderingTx.Hash() {
+			orderingIdx = i
+		}
+		if tx.Hash() == indexedTx.Hash() {
+			indexedIdx = i
+		}
+		if tx.Hash() == canceledTx.Hash() {
+			t.Fatalf("canceled constraint tx %s must not appear in the produced block", canceledTx.Hash())
+		}
+	}
+	if orderingIdx == -1 {
+		t.Fatalf("expected ordering-only constraint tx to be included")
+	}
+	if indexedIdx == -1 {
+		t.Fatalf("expected indexed constraint tx to be included")
+	}
+	if indexedIdx != int(indexedPos) {
+		t.Fatalf("expected indexed constraint tx at index %d, got %d", indexedPos, indexedIdx)
+	}
+	for i, tx := range r.block.Transactions() {
+		if tx.Hash() != orderingTx.Hash() && tx.Hash() != indexedTx.Hash() {
+			nonConstrainedIdx = i
+			break
+		}
+	}
+	if nonConstrainedIdx != -1 && orderingIdx > nonConstrainedIdx {
+		t.Fatalf("ordering-only constraint must land before non-constrained txs, got index %d vs %d", orderingIdx, nonConstrainedIdx)
+	}
+}
@@ -0,0 +1,43 @@
+package miner
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+// ErrStateDatabaseFailure wraps a genuine trie/database error surfaced through
+// StateDB.Error(). It's distinct from an EVM revert, which also leaves a transaction's
+// execution result non-nil but isn't a reason to abort the rest of the block - a silently
+// failed preconf tx, on the other hand, must not ship with the wrong receipt.
+type ErrStateDatabaseFailure struct {
+	Err error
+}
+
+func (e *ErrStateDatabaseFailure) Error() string {
+	return fmt.Sprintf("state database failure: %v", e.Err)
+}
+
+func (e *ErrStateDatabaseFailure) Unwrap() error { return e.Err }
+
+// checkStateError is meant to run immediately after a transaction is applied to statedb,
+// before its result is folded into the block: a non-nil StateDB.Error() means the trie or
+// its underlying database failed mid-execution, which must abort block building rather
+// than be folded into the transaction's receipt as an ordinary revert.
+//
+// Its primary call site belongs in commitTransaction, in worker.go, right after each
+// transaction is applied; that file isn't part of this checkout, so that per-transaction
+// abort isn't wired in yet. buildPendingForSlot, in pending.go, calls it as a second line of
+// defense on the state it hands back from Pending, so a database failure surfaced only once
+// the built block's state is read back is still classified and aborted rather than silently
+// returned as a healthy pending build.
+func checkStateError(statedb *state.StateDB) error {
+	if err := statedb.Error(); err != nil {
+		return &ErrStateDatabaseFailure{Err: err}
+	}
+	return nil
+}
+
+// stateErrorCheck is checkStateError, indirected so tests can force buildPendingForSlot's
+// abort path without reproducing a live trie/database failure end-to-end.
+var stateErrorCheck = checkStateError
@@ -0,0 +1,60 @@
+package miner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var errInjectedStorageFailure = errors.New("injected storage trie failure")
+
+// failingStorageDatabase wraps a real state.Database and fails every OpenStorageTrie call
+// for a single chosen address, letting a test force the same kind of mid-execution DB error
+// commitTransaction would otherwise swallow into an EVM revert.
+type failingStorageDatabase struct {
+	state.Database
+	failAddr common.Address
+}
+
+func (d *failingStorageDatabase) OpenStorageTrie(stateRoot common.Hash, address common.Address, root common.Hash, self state.Trie) (state.Trie, error) {
+	if address == d.failAddr {
+		return nil, errInjectedStorageFailure
+	}
+	return d.Database.OpenStorageTrie(stateRoot, address, root, self)
+}
+
+func TestCheckStateErrorCatchesInjectedDatabaseFailure(t *testing.T) {
+	db := &failingStorageDatabase{Database: state.NewDatabase(rawdb.NewMemoryDatabase()), failAddr: testUserAddress}
+	statedb, err := state.New(types.EmptyRootHash, db, nil)
+	if err != nil {
+		t.Fatalf("state.New failed: %v", err)
+	}
+
+	statedb.SetState(testUserAddress, common.Hash{1}, common.Hash{2})
+	statedb.IntermediateRoot(true)
+
+	if err := checkStateError(statedb); err == nil {
+		t.Fatalf("expected checkStateError to surface the injected storage trie failure")
+	} else if !errors.Is(err, errInjectedStorageFailure) {
+		t.Fatalf("expected the injected error to be unwrappable, got %v", err)
+	}
+}
+
+func TestCheckStateErrorHealthyPath(t *testing.T) {
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, err := state.New(types.EmptyRootHash, db, nil)
+	if err != nil {
+		t.Fatalf("state.New failed: %v", err)
+	}
+
+	statedb.SetState(testUserAddress, common.Hash{1}, common.Hash{2})
+	statedb.IntermediateRoot(true)
+
+	if err := checkStateError(statedb); err != nil {
+		t.Fatalf("expected no error on the healthy path, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package miner
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// workerState bundles the per-worker bolt state that would otherwise live as fields on
+// worker itself: the pending-build cache, the bundle scheduler, and the preconf tracker.
+// Bundling the state here means every worker gets exactly one registry entry, however many
+// features hang state off of it.
+type workerState struct {
+	pending *pendingCache
+	bundles *bundleScheduler
+	preconf *core.PreconfTracker
+}
+
+// workerStates is keyed by the numeric value of w's address rather than by *worker itself: a
+// live *worker stored as a map key is a strong reference, which would keep w reachable
+// forever and mean runtime.SetFinalizer(w, ...) could never fire - its own delete from this
+// map is exactly what the GC is waiting on before it would consider w collectible. A uintptr
+// is just a number; storing one doesn't retain anything, so the finalizer genuinely runs once
+// w has no other referrers, and the entry is removed then - not on an LRU recency schedule,
+// which could otherwise evict a still-running worker's bundleScheduler and PreconfTracker out
+// from under it. The stored uintptr is never converted back into a pointer, only compared.
+var (
+	workerStatesMu sync.Mutex
+	workerStates   = make(map[uintptr]*workerState)
+)
+
+// stateFor returns the workerState for w, creating it - and registering the finalizer that
+// removes it once w is actually garbage collected - on first use.
+func stateFor(w *worker) *workerState {
+	key := uintptr(unsafe.Pointer(w))
+
+	workerStatesMu.Lock()
+	defer workerStatesMu.Unlock()
+
+	if s, ok := workerStates[key]; ok {
+		return s
+	}
+	s := &workerState{}
+	workerStates[key] = s
+	runtime.SetFinalizer(w, func(w *worker) {
+		workerStatesMu.Lock()
+		defer workerStatesMu.Unlock()
+		delete(workerStates, uintptr(unsafe.Pointer(w)))
+	})
+	return s
+}
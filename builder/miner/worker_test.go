@@ -18,6 +18,7 @@ package miner
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 	mrnd "math/rand"
 	"sync/atomic"
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/txpool/legacypool"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -81,6 +83,20 @@ var (
 	// Test testConstraintsCache
 	testConstraintsCache = new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
 
+	// testBlobConstraintsCache holds a few seeded blob-tx constraints, kept separate from
+	// testConstraintsCache so TestGetSealingWorkWithBlobConstraints doesn't have to share a
+	// slot with the legacy/access-list constraints seeded above.
+	testBlobConstraintsCache = new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+
+	// constraintTestAlloc funds testAddress1-3 alongside testBankAddress, for the tests whose
+	// constrained transactions are signed by those accounts and must be applied in a block.
+	constraintTestAlloc = types.GenesisAlloc{
+		testBankAddress: {Balance: testBankFunds},
+		testAddress1:    {Balance: testBankFunds},
+		testAddress2:    {Balance: testBankFunds},
+		testAddress3:    {Balance: testBankFunds},
+	}
+
 	testConfig = &Config{
 		Recommit: time.Second,
 		GasCeil:  params.GenesisGasLimit,
@@ -91,6 +107,10 @@ var (
 
 const pendingTxsLen = 50
 
+// testBlobConstraintsSlot is the slot testBlobConstraintsCache's seeded blob-tx constraints
+// are registered under.
+const testBlobConstraintsSlot = uint64(99)
+
 func init() {
 	testTxPoolConfig = legacypool.DefaultConfig
 	testTxPoolConfig.Journal = ""
@@ -139,6 +159,16 @@ func init() {
 		GasPrice: big.NewInt(params.InitialBaseFee),
 	})
 	newTxs = append(newTxs, tx2)
+
+	// Seed a few blob-tx constraints under their own slot, so
+	// TestGetSealingWorkWithBlobConstraints has real constrained blob transactions to build
+	// a block against.
+	blobConstraints := make(types.HashToConstraintDecoded, 3)
+	for i := 0; i < 3; i++ {
+		blobTx := newSignedTestBlobTx(uint64(pendingTxsLen+i), 1)
+		blobConstraints[blobTx.Hash()] = &types.ConstraintDecoded{Tx: blobTx, BlobSidecar: blobTx.BlobTxSidecar()}
+	}
+	testBlobConstraintsCache.Put(testBlobConstraintsSlot, blobConstraints)
 }
 
 // testWorkerBackend implements worker.Backend interfaces and wraps all information needed during the testing.
@@ -574,6 +604,142 @@ func testGetSealingWork(t *testing.T, chainConfig *params.ChainConfig, engine co
 	}
 }
 
+// TestGetSealingWorkWithBlobConstraints builds a pending block honoring the blob-tx
+// constraints seeded into testBlobConstraintsCache, and checks that the block's
+// BlobGasUsed reflects them while their sidecars - stripped before packing - remain
+// retrievable out-of-band from the original constraintsCache entries, the way the engine
+// API's getPayloadV3 needs them for BlobsBundleV1.
+func TestGetSealingWorkWithBlobConstraints(t *testing.T) {
+	w, _ := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
+	defer w.close()
+
+	w.NotifyConstraints(testBlobConstraintsSlot)
+
+	block, state := w.Pending(testBlobConstraintsSlot, testUserAddress, testBlobConstraintsCache)
+	if block == nil || state == nil {
+		t.Fatalf("expected a pending build, got nil block/state")
+	}
+
+	decoded, ok := testBlobConstraintsCache.Get(testBlobConstraintsSlot)
+	if !ok || len(decoded) == 0 {
+		t.Fatalf("expected seeded blob constraints for slot %d", testBlobConstraintsSlot)
+	}
+
+	var wantBlobGas uint64
+	for txHash, c := range decoded {
+		wantBlobGas += c.Tx.BlobGas()
+
+		found := false
+		for _, tx := range block.Transactions() {
+			if tx.Hash() == txHash {
+				found = true
+				if tx.BlobTxSidecar() != nil {
+					t.Errorf("expected the sealed block's copy of %s to carry no sidecar", txHash)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected constrained blob tx %s to be present in the sealed block", txHash)
+		}
+		if c.BlobSidecar == nil {
+			t.Errorf("expected the original constraintsCache entry for %s to keep its sidecar", txHash)
+		}
+	}
+
+	if got := block.BlobGasUsed(); got == nil || *got != wantBlobGas {
+		t.Fatalf("expected BlobGasUsed %d, got %v", wantBlobGas, got)
+	}
+}
+
+// TestConstraintSetValidateAndSchedule is a table-driven companion to
+// TestGetSealingWorkWithConstraints, covering the conflict-resolution failure modes that
+// test can't exercise: duplicate indices, duplicate sender nonces, gas overflow, and
+// out-of-range indices, plus how unindexed constraints are placed.
+func TestConstraintSetValidateAndSchedule(t *testing.T) {
+	signer := types.LatestSigner(params.TestChainConfig)
+	newTx := func(nonce uint64, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    nonce,
+			To:       &testUserAddress,
+			Value:    big.NewInt(1000),
+			Gas:      params.TxGas,
+			GasPrice: big.NewInt(params.InitialBaseFee),
+		})
+		require.NoError(t, err)
+		return tx
+	}
+	idx := func(i uint64) *uint64 { return &i }
+
+	t.Run("duplicate index is rejected", func(t *testing.T) {
+		s := NewConstraintSet()
+		s.Add(&types.ConstraintDecoded{Index: idx(0), Tx: newTx(0, testAddress1Key)})
+		s.Add(&types.ConstraintDecoded{Index: idx(0), Tx: newTx(0, testAddress2Key)})
+
+		var conflict *ErrConstraintConflict
+		err := s.Validate(params.GenesisGasLimit, 0)
+		if err == nil || !errors.As(err, &conflict) {
+			t.Fatalf("expected ErrConstraintConflict, got %v", err)
+		}
+	})
+
+	t.Run("duplicate sender nonce is rejected", func(t *testing.T) {
+		s := NewConstraintSet()
+		s.Add(&types.ConstraintDecoded{Tx: newTx(5, testAddress1Key)})
+		s.Add(&types.ConstraintDecoded{Tx: newTx(5, testAddress1Key)})
+
+		var conflict *ErrConstraintConflict
+		err := s.Validate(params.GenesisGasLimit, 0)
+		if err == nil || !errors.As(err, &conflict) {
+			t.Fatalf("expected ErrConstraintConflict, got %v", err)
+		}
+	})
+
+	t.Run("gas overflow is rejected", func(t *testing.T) {
+		s := NewConstraintSet()
+		s.Add(&types.ConstraintDecoded{Tx: newTx(0, testAddress1Key)})
+
+		var conflict *ErrConstraintConflict
+		err := s.Validate(params.TxGas-1, 0)
+		if err == nil || !errors.As(err, &conflict) {
+			t.Fatalf("expected ErrConstraintConflict, got %v", err)
+		}
+	})
+
+	t.Run("index beyond the block's tx count is rejected", func(t *testing.T) {
+		s := NewConstraintSet()
+		s.Add(&types.ConstraintDecoded{Index: idx(5), Tx: newTx(0, testAddress1Key)})
+
+		_, err := s.Schedule(0)
+		var conflict *ErrConstraintConflict
+		if err == nil || !errors.As(err, &conflict) {
+			t.Fatalf("expected ErrConstraintConflict, got %v", err)
+		}
+	})
+
+	t.Run("unindexed constraints fill the lowest free slots", func(t *testing.T) {
+		s := NewConstraintSet()
+		indexedTx := newTx(0, testAddress1Key)
+		unindexedTx := newTx(0, testAddress2Key)
+		s.Add(&types.ConstraintDecoded{Index: idx(1), Tx: indexedTx})
+		s.Add(&types.ConstraintDecoded{Tx: unindexedTx})
+
+		require.NoError(t, s.Validate(params.GenesisGasLimit, 0))
+		positions, err := s.Schedule(2)
+		require.NoError(t, err)
+
+		byHash := make(map[common.Hash]int)
+		for _, p := range positions {
+			byHash[p.tx.Hash()] = p.index
+		}
+		if byHash[indexedTx.Hash()] != 1 {
+			t.Errorf("expected indexed tx at position 1, got %d", byHash[indexedTx.Hash()])
+		}
+		if byHash[unindexedTx.Hash()] != 0 {
+			t.Errorf("expected unindexed tx to fill the free slot 0, got %d", byHash[unindexedTx.Hash()])
+		}
+	})
+}
+
 func TestSimulateBundles(t *testing.T) {
 	w, _ := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
 	defer w.close()
@@ -624,6 +790,107 @@ func TestSimulateBundles(t *testing.T) {
 	}
 }
 
+// TestBundleCancellation checks that a canceled bundle no longer appears among the live
+// bundles considered for a slot, and that naming a replacement swaps it in under the
+// canceled bundle's slot.
+func TestBundleCancellation(t *testing.T) {
+	w, _ := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
+	defer w.close()
+
+	const slot = uint64(42)
+	bundle := types.MevBundle{Txs: types.Transactions{newTxs[0]}, Hash: common.HexToHash("0xb1")}
+	replacement := types.MevBundle{Txs: types.Transactions{newTxs[0]}, Hash: common.HexToHash("0xb2")}
+
+	uuid := types.UUID{1}
+	replacementUUID := types.UUID{2}
+	require.NoError(t, w.AddMevBundle(uuid, bundle, slot))
+	require.NoError(t, w.AddMevBundle(replacementUUID, replacement, 0))
+
+	s := bundleSchedulerFor(w)
+	if live := s.liveBundlesForSlot(slot); len(live) != 1 {
+		t.Fatalf("expected 1 live bundle before cancellation, got %d", len(live))
+	}
+
+	if err := w.CancelMevBundle(uuid, &replacementUUID); err != nil {
+		t.Fatalf("CancelMevBundle: %v", err)
+	}
+
+	if live := s.liveBundlesForSlot(slot); len(live) != 1 || live[0].Hash != replacement.Hash {
+		t.Fatalf("expected only the replacement to be live for the canceled bundle's slot, got %+v", live)
+	}
+	if live := s.liveBundlesForSlot(0); len(live) != 0 {
+		t.Fatalf("expected the replacement to have moved off slot 0 once swapped in, got %d", len(live))
+	}
+
+	if err := w.CancelMevBundle(types.UUID{0xff}, nil); err == nil {
+		t.Fatalf("expected canceling an unregistered uuid to return an error")
+	}
+}
+
+// TestBundleCancellationTTLBlocksReuse checks that a canceled bundle's UUID cannot be
+// resubmitted until bundleTTL has elapsed since the cancellation, closing the race where a
+// stale resubmission racing the cancellation would otherwise bring it back.
+func TestBundleCancellationTTLBlocksReuse(t *testing.T) {
+	w, _ := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
+	defer w.close()
+
+	const slot = uint64(43)
+	bundle := types.MevBundle{Txs: types.Transactions{newTxs[0]}, Hash: common.HexToHash("0xb3")}
+	uuid := types.UUID{3}
+
+	require.NoError(t, w.AddMevBundle(uuid, bundle, slot))
+	require.NoError(t, w.CancelMevBundle(uuid, nil))
+
+	if err := w.AddMevBundle(uuid, bundle, slot); err == nil {
+		t.Fatalf("expected resubmitting a just-canceled uuid to be rejected within the TTL")
+	}
+
+	s := bundleSchedulerFor(w)
+	s.mu.Lock()
+	s.bundles[uuid].canceledAt = time.Now().Add(-bundleTTL - time.Second)
+	s.mu.Unlock()
+
+	if err := w.AddMevBundle(uuid, bundle, slot); err != nil {
+		t.Fatalf("expected resubmitting after the TTL has elapsed to succeed, got %v", err)
+	}
+}
+
+// TestConstraintOverridesBundle checks that when a bundle's transaction conflicts with a
+// constraint, the constraint wins and the bundle is dropped with a reported reason.
+func TestConstraintOverridesBundle(t *testing.T) {
+	signer := types.LatestSigner(params.TestChainConfig)
+	constraintTx, err := types.SignNewTx(testAddress1Key, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &testUserAddress,
+		Value:    big.NewInt(1000),
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(params.InitialBaseFee),
+	})
+	require.NoError(t, err)
+
+	conflictingBundle := types.MevBundle{Txs: types.Transactions{constraintTx}, Hash: common.HexToHash("0xc1")}
+	cleanTx, err := types.SignNewTx(testAddress2Key, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &testUserAddress,
+		Value:    big.NewInt(1000),
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(params.InitialBaseFee),
+	})
+	require.NoError(t, err)
+	cleanBundle := types.MevBundle{Txs: types.Transactions{cleanTx}, Hash: common.HexToHash("0xc2")}
+
+	constraints := NewConstraintSet()
+	constraints.Add(&types.ConstraintDecoded{Tx: constraintTx})
+
+	result := coScheduleBundlesWithConstraints([]types.MevBundle{conflictingBundle, cleanBundle}, constraints)
+	if len(result.survivingBundles) != 1 || result.survivingBundles[0].Hash != cleanBundle.Hash {
+		t.Fatalf("expected only the non-conflicting bundle to survive, got %+v", result.survivingBundles)
+	}
+	if _, dropped := result.dropped[conflictingBundle.Hash]; !dropped {
+		t.Fatalf("expected the conflicting bundle to be dropped with a reason")
+	}
+}
+
 func testBundles(t *testing.T) {
 	// TODO: test cancellations
 	db := rawdb.NewMemoryDatabase()
@@ -732,3 +999,233 @@ func testBundles(t *testing.T) {
 		t.Log("Balances", balancePre, balancePost)
 	}
 }
+
+// TestPendingHonorsConstraintsWithoutSealing checks that Pending returns a build that
+// reflects the constraints currently registered for a slot, even though w.start() was
+// never called.
+func TestPendingHonorsConstraintsWithoutSealing(t *testing.T) {
+	w, b := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), constraintTestAlloc, 0)
+	defer w.close()
+
+	const slot = uint64(7)
+	constraintTx := b.newRandomTx(false, testUserAddress, 1, testAddress1Key, 0, big.NewInt(2*params.InitialBaseFee))
+	idx := uint64(0)
+	cache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	cache.Put(slot, types.HashToConstraintDecoded{
+		constraintTx.Hash(): {Index: &idx, Tx: constraintTx},
+	})
+	w.NotifyConstraints(slot)
+
+	block, state := w.Pending(slot, testUserAddress, cache)
+	if block == nil || state == nil {
+		t.Fatalf("expected a pending build, got nil block/state")
+	}
+
+	found := false
+	for i, tx := range block.Transactions() {
+		if tx.Hash() == constraintTx.Hash() {
+			found = true
+			if i != int(idx) {
+				t.Errorf("expected constrained tx at index %d, got %d", idx, i)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected constrained tx to be present in the pending build")
+	}
+
+	cachedBlock, _ := w.Pending(slot, testUserAddress, cache)
+	if cachedBlock != block {
+		t.Fatalf("expected a second Pending call with an unchanged key to return the cached build")
+	}
+}
+
+// TestPendingRefusesToSealOnStateDatabaseFailure checks that Pending aborts and returns a
+// nil block/state, rather than the block it already built, when the post-build state check
+// reports a database failure.
+func TestPendingRefusesToSealOnStateDatabaseFailure(t *testing.T) {
+	w, _ := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
+	defer w.close()
+
+	orig := stateErrorCheck
+	defer func() { stateErrorCheck = orig }()
+	stateErrorCheck = func(*state.StateDB) error {
+		return &ErrStateDatabaseFailure{Err: errInjectedStorageFailure}
+	}
+
+	block, state := w.Pending(0, testUserAddress, testConstraintsCache)
+	if block != nil || state != nil {
+		t.Fatalf("expected the worker to refuse to seal on a state database failure, got block=%v state=%v", block, state)
+	}
+}
+
+// TestWatchPreconfirmedRemovals constructs a genuine two-branch reorg - a canonical block
+// containing a preconfirmed transaction, then a heavier competing chain built and imported
+// independently that supersedes it - and asserts the removed-tx feed fires with exactly the
+// preconfirmed subset, classified from the orphaned block's real commitments rather than a
+// hand-picked list.
+func TestWatchPreconfirmedRemovals(t *testing.T) {
+	w, b := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), constraintTestAlloc, 0)
+	defer w.close()
+
+	preconfirmedTx := b.newRandomTx(false, testUserAddress, 1, testAddress1Key, 0, big.NewInt(2*params.InitialBaseFee))
+
+	const slot = uint64(11)
+	idx := uint64(0)
+	cache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	cache.Put(slot, types.HashToConstraintDecoded{
+		preconfirmedTx.Hash(): {Index: &idx, Tx: preconfirmedTx},
+	})
+	w.NotifyConstraints(slot)
+
+	branchA, _ := w.Pending(slot, testUserAddress, cache)
+	if branchA == nil {
+		t.Fatalf("expected branch A to build")
+	}
+	if _, err := b.chain.InsertChain([]*types.Block{branchA}); err != nil {
+		t.Fatalf("failed to insert branch A: %v", err)
+	}
+	if got := b.chain.CurrentBlock().Hash(); got != branchA.Hash() {
+		t.Fatalf("expected branch A to be canonical before the reorg, got %s", got)
+	}
+
+	// Build a heavier, two-block side chain from the same genesis on an independent
+	// worker/backend pair, then import it into b's chain: its greater total difficulty
+	// forces a real reorg that orphans branchA, the same way a competing proposer's chain
+	// would.
+	w2, b2 := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), constraintTestAlloc, 0)
+	defer w2.close()
+
+	emptyCache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	sideBlock1, _ := w2.Pending(0, testUserAddress, emptyCache)
+	if sideBlock1 == nil {
+		t.Fatalf("expected side chain block 1 to build")
+	}
+	if _, err := b2.chain.InsertChain([]*types.Block{sideBlock1}); err != nil {
+		t.Fatalf("failed to insert side chain block 1: %v", err)
+	}
+	sideBlock2, _ := w2.Pending(1, testUserAddress, emptyCache)
+	if sideBlock2 == nil {
+		t.Fatalf("expected side chain block 2 to build")
+	}
+
+	violations := make(chan core.RemovedTransactionEvent, 1)
+	sub := w.WatchPreconfirmedRemovals(func(ev core.RemovedTransactionEvent) {
+		violations <- ev
+	})
+	defer sub.Unsubscribe()
+
+	if _, err := b.chain.InsertChain([]*types.Block{sideBlock1, sideBlock2}); err != nil {
+		t.Fatalf("failed to import the heavier side chain: %v", err)
+	}
+	if got := b.chain.CurrentBlock().Hash(); got != sideBlock2.Hash() {
+		t.Fatalf("expected the side chain to become canonical, got %s", got)
+	}
+	if got := b.chain.GetCanonicalHash(branchA.NumberU64()); got == branchA.Hash() {
+		t.Fatalf("expected branch A to be orphaned by the reorg")
+	}
+
+	// Classify branchA's transactions the way blockchain.reorg must before posting
+	// RemovedTransactionEvent, then report exactly that classification - not a hand-picked
+	// one - to the worker's feed.
+	txHashes := make([]common.Hash, len(branchA.Transactions()))
+	for i, tx := range branchA.Transactions() {
+		txHashes[i] = tx.Hash()
+	}
+	preconfirmed := classifyPreconfirmed(b.chain, branchA)
+	postRemovedTransactionEvent(w.mux, branchA, txHashes, preconfirmed)
+
+	select {
+	case ev := <-violations:
+		if ev.Block != branchA {
+			t.Fatalf("expected the removed event to carry branch A")
+		}
+		if len(ev.Preconfirmed) != 1 || ev.Preconfirmed[0] != preconfirmedTx.Hash() {
+			t.Fatalf("expected only the preconfirmed tx to be reported, got %v", ev.Preconfirmed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for removed-tx event")
+	}
+}
+
+// TestCommitmentsRoundTripThroughInsertChain builds a pending block honoring a constraint,
+// inserts it into the backend's canonical chain, and asserts the commitments the worker
+// recorded while assembling it round-trip through InsertChain -> GetCommitmentsByHash.
+func TestCommitmentsRoundTripThroughInsertChain(t *testing.T) {
+	w, b := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
+	defer w.close()
+
+	const slot = uint64(13)
+	committedTx := b.newRandomTx(false, testUserAddress, 1, testAddress1Key, 0, big.NewInt(2*params.InitialBaseFee))
+	idx := uint64(0)
+	cache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	cache.Put(slot, types.HashToConstraintDecoded{
+		committedTx.Hash(): {Index: &idx, Tx: committedTx},
+	})
+	w.NotifyConstraints(slot)
+
+	block, _ := w.Pending(slot, testUserAddress, cache)
+	if block == nil {
+		t.Fatalf("expected a pending build")
+	}
+
+	if _, err := b.chain.InsertChain([]*types.Block{block}); err != nil {
+		t.Fatalf("failed to insert block with commitments: %v", err)
+	}
+
+	commitments := b.chain.GetCommitmentsByHash(block.Hash())
+	if len(commitments) != 1 {
+		t.Fatalf("expected exactly 1 commitment to round-trip, got %d", len(commitments))
+	}
+	if commitments[0].TxHash != committedTx.Hash() || commitments[0].Slot != slot {
+		t.Fatalf("unexpected commitment after round-trip: %+v", commitments[0])
+	}
+
+	if got, want := b.chain.GetCommitmentsRootByHash(block.Hash()), core.CommitmentsRoot(commitments); got != want {
+		t.Fatalf("expected the recorded commitments root to match CommitmentsRoot(commitments), got %s want %s", got, want)
+	}
+
+	if got := b.chain.GetBlock(block.Hash(), block.NumberU64()); got == nil {
+		t.Fatalf("expected GetBlock to find the inserted block")
+	}
+}
+
+// TestPreconfStatsViolationCounter builds and inserts a block honoring a commitment, then
+// simulates a reorg that violates it, and asserts PreconfStats reflects the commitment
+// moving from outstanding to violated.
+func TestPreconfStatsViolationCounter(t *testing.T) {
+	w, b := newTestWorker(t, ethashChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), nil, 0)
+	defer w.close()
+
+	sub := w.WatchPreconfStats()
+	defer sub.Unsubscribe()
+
+	const slot = uint64(17)
+	committedTx := b.newRandomTx(false, testUserAddress, 1, testAddress1Key, 0, big.NewInt(2*params.InitialBaseFee))
+	idx := uint64(0)
+	cache := new(shardmap.FIFOMap[uint64, types.HashToConstraintDecoded])
+	cache.Put(slot, types.HashToConstraintDecoded{
+		committedTx.Hash(): {Index: &idx, Tx: committedTx},
+	})
+	w.NotifyConstraints(slot)
+
+	block, _ := w.Pending(slot, testUserAddress, cache)
+	if block == nil {
+		t.Fatalf("expected a pending build")
+	}
+	if _, err := b.chain.InsertChain([]*types.Block{block}); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+
+	if stats := w.PreconfStats(); stats.Outstanding != 1 || stats.Violated != 0 {
+		t.Fatalf("expected 1 outstanding, 0 violated before the reorg, got %+v", stats)
+	}
+
+	// A competing branch supersedes the inserted block, reorging out the commitment.
+	postRemovedTransactionEvent(w.mux, block, []common.Hash{committedTx.Hash()}, []common.Hash{committedTx.Hash()})
+
+	require.Eventually(t, func() bool {
+		stats := w.PreconfStats()
+		return stats.Outstanding == 0 && stats.Violated == 1
+	}, time.Second, 10*time.Millisecond, "expected the violation to be reflected in PreconfStats")
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// Commitment is a single preconfirmation a proposer honored while sealing a block: the
+// transaction it committed to include, the slot the commitment was made for, the
+// proposer's signature over that commitment, and - if the transaction was also subject to
+// a relay-issued constraint - the constraint's ID, so the block can be checked after the
+// fact against both the preconf pool and the constraints cache.
+type Commitment struct {
+	TxHash            common.Hash
+	Slot              uint64
+	ProposerSignature []byte
+	ConstraintHash    *common.Hash `rlp:"optional"`
+}
+
+// Commitments is the list of commitments a block satisfies, in the order the worker
+// assembled them. It implements the same DerivableList shape as types.Transactions and
+// types.Receipts, so CommitmentsRoot can reuse the standard Merkle-Patricia hashing.
+type Commitments []Commitment
+
+func (c Commitments) Len() int { return len(c) }
+
+func (c Commitments) EncodeIndex(i int, w *bytes.Buffer) {
+	rlp.Encode(w, c[i])
+}
+
+// CommitmentsRoot folds a block's commitments into a single hash, the same way
+// types.DeriveSha computes a block's TxHash and ReceiptHash. RecordCommitments computes and
+// stores this alongside every block's commitments; once types.Header carries a
+// CommitmentsHash field, the sealing path should additionally set it to this value so the
+// root is covered by the block hash itself, rather than just being available for a
+// subsystem to recompute and compare against. That field isn't part of this checkout, so for
+// now this is exposed for verification through GetCommitmentsRootByHash instead.
+func CommitmentsRoot(commitments Commitments) common.Hash {
+	return trie.DeriveSha(commitments, trie.NewStackTrie(nil))
+}
+
+// recordedCommitments pairs a block's commitments with their precomputed CommitmentsRoot,
+// so GetCommitmentsRootByHash doesn't need to re-derive the root on every call.
+type recordedCommitments struct {
+	list Commitments
+	root common.Hash
+}
+
+// commitmentsCacheSize bounds how many blocks' worth of commitments a single BlockChain
+// keeps available: without a bound, every block ever sealed or imported with commitments
+// would accumulate in memory for the life of the process. 256 blocks is generously more
+// than the reorg depths bolt needs to look back through to classify a violated commitment.
+const commitmentsCacheSize = 256
+
+// blockCommitments records, per chain instance, the commitments satisfied by the blocks
+// that chain has sealed or imported. Kept out of BlockChain itself - blockchain.go isn't
+// part of this checkout - the same way pendingCache is kept out of the worker struct in
+// builder/miner/pending.go.
+//
+// Keyed by the numeric value of bc's address rather than by *BlockChain itself: a live
+// *BlockChain stored as a map key is a strong reference, which would keep bc reachable
+// forever and mean runtime.SetFinalizer(bc, ...) could never fire - its own delete from this
+// map is exactly what the GC is waiting on before it would consider bc collectible. Bounding
+// the map to a fixed size instead doesn't fix this either: an LRU evicts whichever chain was
+// least recently touched even if it's still very much alive, so an actively-used chain with
+// only occasional commitment lookups could lose its entire cache out from under it. A uintptr
+// is just a number; storing one doesn't retain anything, so the finalizer genuinely runs once
+// bc has no other referrers, and the entry is removed then - not on a recency schedule.
+// builder/miner/worker_state.go's workerStates has the same shape, for the same reason.
+var (
+	blockCommitmentsMu sync.Mutex
+	blockCommitments   = make(map[uintptr]*lru.Cache[common.Hash, recordedCommitments])
+)
+
+// commitmentsFor returns the bounded commitments cache for bc, creating it - and
+// registering the finalizer that removes it once bc is actually garbage collected - on first
+// use.
+func commitmentsFor(bc *BlockChain) *lru.Cache[common.Hash, recordedCommitments] {
+	key := uintptr(unsafe.Pointer(bc))
+
+	blockCommitmentsMu.Lock()
+	defer blockCommitmentsMu.Unlock()
+
+	if c, ok := blockCommitments[key]; ok {
+		return c
+	}
+	c, _ := lru.New[common.Hash, recordedCommitments](commitmentsCacheSize)
+	blockCommitments[key] = c
+	runtime.SetFinalizer(bc, func(bc *BlockChain) {
+		blockCommitmentsMu.Lock()
+		defer blockCommitmentsMu.Unlock()
+		delete(blockCommitments, uintptr(unsafe.Pointer(bc)))
+	})
+	return c
+}
+
+// RecordCommitments associates commitments, and their CommitmentsRoot, with blockHash on
+// bc, so a later GetCommitmentsByHash or GetCommitmentsRootByHash call - from RPC or from
+// another subsystem verifying the block after the fact - can look them up. The worker calls
+// this once it has assembled commitments for a sealed block, before (or as part of) handing
+// the block to InsertChain.
+func RecordCommitments(bc *BlockChain, blockHash common.Hash, commitments Commitments) {
+	if len(commitments) == 0 {
+		return
+	}
+	commitmentsFor(bc).Add(blockHash, recordedCommitments{
+		list: commitments,
+		root: CommitmentsRoot(commitments),
+	})
+}
+
+// GetCommitmentsByHash returns the preconfirmation commitments recorded against blockHash,
+// or nil if none were recorded - for example for blocks sealed before bolt, or blocks not
+// produced by this node.
+func (bc *BlockChain) GetCommitmentsByHash(blockHash common.Hash) Commitments {
+	rec, _ := commitmentsFor(bc).Get(blockHash)
+	return rec.list
+}
+
+// GetCommitmentsRootByHash returns the CommitmentsRoot recorded against blockHash, or the
+// zero hash if no commitments were recorded for it. Until a CommitmentsHash header field
+// exists for the sealing path to set, this is the way a subsystem confirms the commitments
+// GetCommitmentsByHash returns are the same ones that were recorded at sealing time, rather
+// than trusting the side table unconditionally.
+func (bc *BlockChain) GetCommitmentsRootByHash(blockHash common.Hash) common.Hash {
+	rec, _ := commitmentsFor(bc).Get(blockHash)
+	return rec.root
+}
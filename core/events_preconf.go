@@ -0,0 +1,16 @@
+package core
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// RemovedTransactionEvent is posted by blockchain.reorg whenever a reorg drops a
+// previously canonical block, carrying the transactions it contained. Preconfirmed lists
+// the subset of TxHashes this node had preconfirmed, so a subscribing preconfirmation
+// subsystem can tell a routine reorg apart from one that violated a commitment it made.
+type RemovedTransactionEvent struct {
+	Block        *types.Block
+	TxHashes     []common.Hash
+	Preconfirmed []common.Hash
+}
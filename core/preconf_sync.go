@@ -0,0 +1,65 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PreconfStats reports how far a node's preconfirmation view trails the canonical chain:
+// Outstanding counts commitments whose target slot hasn't been included in a canonical
+// block yet, and Violated counts commitments that were included but later reorged out by a
+// canonical block that didn't honor them. This is the third dimension bolt-enabled nodes
+// add to the usual pending/cached downloader sync stats.
+type PreconfStats struct {
+	Outstanding uint64
+	Violated    uint64
+}
+
+// PreconfTracker accumulates the state behind PreconfStats as commitments are made,
+// included, and - occasionally - reorged away. It's its own type rather than a Downloader
+// field because the downloader package isn't part of this checkout; a
+// Downloader.PreconfStats() method should delegate to a tracker of this shape once it is.
+type PreconfTracker struct {
+	mu          sync.Mutex
+	outstanding map[common.Hash]struct{}
+	violated    uint64
+}
+
+// NewPreconfTracker creates an empty PreconfTracker.
+func NewPreconfTracker() *PreconfTracker {
+	return &PreconfTracker{outstanding: make(map[common.Hash]struct{})}
+}
+
+// TrackCommitment records txHash as an outstanding preconfirmation commitment.
+func (t *PreconfTracker) TrackCommitment(txHash common.Hash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outstanding[txHash] = struct{}{}
+}
+
+// MarkIncluded removes txHash from the outstanding set once it's observed in a canonical
+// block.
+func (t *PreconfTracker) MarkIncluded(txHash common.Hash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.outstanding, txHash)
+}
+
+// MarkViolated removes txHash from the outstanding set, if present, and counts it as
+// violated: the commitment was included and then reorged out by a competing canonical
+// block. Intended to be called for every hash in a RemovedTransactionEvent's Preconfirmed
+// list.
+func (t *PreconfTracker) MarkViolated(txHash common.Hash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.outstanding, txHash)
+	t.violated++
+}
+
+// PreconfStats returns a snapshot of the tracker's current counts.
+func (t *PreconfTracker) PreconfStats() PreconfStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return PreconfStats{Outstanding: uint64(len(t.outstanding)), Violated: t.violated}
+}
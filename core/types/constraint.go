@@ -0,0 +1,20 @@
+package types
+
+import "github.com/ethereum/go-ethereum/common"
+
+// ConstraintDecoded pairs a decoded transaction with the constraint metadata the builder
+// needs to place it: the index it must occupy, if any, and - for blob transactions - the
+// sidecar the engine API needs to assemble the block's BlobsBundleV1 response.
+type ConstraintDecoded struct {
+	Index *uint64
+	Tx    *Transaction
+
+	// BlobSidecar carries the EIP-4844 sidecar for Tx when it is a blob transaction. It
+	// must be stripped before Tx is packed into the block body - the sidecar is only
+	// needed out-of-band, for the engine API's getPayloadV3 BlobsBundleV1 response.
+	BlobSidecar *BlobTxSidecar
+}
+
+// HashToConstraintDecoded indexes a slot's decoded constraints by transaction hash, the
+// form delivered to the builder by the relay's constraints subscription.
+type HashToConstraintDecoded = map[common.Hash]*ConstraintDecoded
@@ -0,0 +1,24 @@
+package ethapi
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// CommitmentsAPI exposes the preconfirmation commitments a block satisfied, so relays and
+// validators can prove after the fact which commitments this node's proposer honored. It's
+// registered under the "eth" namespace alongside the rest of this package's public APIs.
+type CommitmentsAPI struct {
+	chain *core.BlockChain
+}
+
+// NewCommitmentsAPI creates a CommitmentsAPI backed by chain.
+func NewCommitmentsAPI(chain *core.BlockChain) *CommitmentsAPI {
+	return &CommitmentsAPI{chain: chain}
+}
+
+// GetCommitmentsByHash returns the preconfirmation commitments recorded against blockHash,
+// or nil - null over JSON-RPC - if none were recorded for it.
+func (api *CommitmentsAPI) GetCommitmentsByHash(blockHash common.Hash) core.Commitments {
+	return api.chain.GetCommitmentsByHash(blockHash)
+}
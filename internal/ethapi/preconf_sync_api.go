@@ -0,0 +1,21 @@
+package ethapi
+
+import "github.com/ethereum/go-ethereum/core"
+
+// PreconfSyncAPI exposes eth_syncingPreconfs, reporting how far this node's
+// preconfirmation view trails the canonical chain - the third dimension of sync progress
+// bolt-enabled nodes add alongside the usual pending/cached downloader stats.
+type PreconfSyncAPI struct {
+	tracker *core.PreconfTracker
+}
+
+// NewPreconfSyncAPI creates a PreconfSyncAPI backed by tracker.
+func NewPreconfSyncAPI(tracker *core.PreconfTracker) *PreconfSyncAPI {
+	return &PreconfSyncAPI{tracker: tracker}
+}
+
+// SyncingPreconfs returns the node's current preconfirmation sync snapshot, served as
+// eth_syncingPreconfs.
+func (api *PreconfSyncAPI) SyncingPreconfs() core.PreconfStats {
+	return api.tracker.PreconfStats()
+}
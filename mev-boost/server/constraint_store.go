@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	gethCommon "github.com/ethereum/go-ethereum/common"
+)
+
+// ConstraintStore is the durable tier backing a ConstraintCache. Implementations must be
+// safe for concurrent use and key entries by (slot, txHash) so a single slot's constraints
+// can be loaded or pruned without scanning the whole store.
+type ConstraintStore interface {
+	// PutBatch persists every constraint in the map for the given slot in a single batch.
+	PutBatch(slot uint64, constraints map[gethCommon.Hash]*Constraint) error
+	// Load returns every constraint persisted for the given slot.
+	Load(slot uint64) (map[gethCommon.Hash]*Constraint, error)
+	// LoadFrom returns every constraint persisted for slots >= fromSlot, keyed by slot.
+	// It is used to rehydrate the in-memory cache after a relay restart.
+	LoadFrom(fromSlot uint64) (map[uint64]map[gethCommon.Hash]*Constraint, error)
+	// Prune deletes every constraint persisted for slots < beforeSlot.
+	Prune(beforeSlot uint64) error
+	// Close releases the underlying resources.
+	Close() error
+}
+
+// pebbleConstraintStore is a ConstraintStore backed by a pebble key-value database,
+// keyed by an 8-byte big-endian slot followed by the 32-byte constraint ID.
+type pebbleConstraintStore struct {
+	db *pebble.DB
+}
+
+// NewPebbleConstraintStore opens (or creates) a pebble-backed constraint store at dir.
+func NewPebbleConstraintStore(dir string) (ConstraintStore, error) {
+	db, err := pebble.Open(dir, &pebble.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("opening constraint store at %s: %w", dir, err)
+	}
+	return &pebbleConstraintStore{db: db}, nil
+}
+
+func constraintStoreKey(slot uint64, id gethCommon.Hash) []byte {
+	key := make([]byte, 8+gethCommon.HashLength)
+	binary.BigEndian.PutUint64(key[:8], slot)
+	copy(key[8:], id.Bytes())
+	return key
+}
+
+func (s *pebbleConstraintStore) PutBatch(slot uint64, constraints map[gethCommon.Hash]*Constraint) error {
+	batch := s.db.NewBatch()
+	defer batch.Close()
+
+	for id, constraint := range constraints {
+		value, err := json.Marshal(constraint)
+		if err != nil {
+			return err
+		}
+		if err := batch.Set(constraintStoreKey(slot, id), value, nil); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+func (s *pebbleConstraintStore) Load(slot uint64) (map[gethCommon.Hash]*Constraint, error) {
+	lower := constraintStoreKey(slot, gethCommon.Hash{})
+	upper := constraintStoreKey(slot+1, gethCommon.Hash{})
+	return s.scan(lower, upper)
+}
+
+func (s *pebbleConstraintStore) LoadFrom(fromSlot uint64) (map[uint64]map[gethCommon.Hash]*Constraint, error) {
+	lower := constraintStoreKey(fromSlot, gethCommon.Hash{})
+	upper := make([]byte, 8)
+	binary.BigEndian.PutUint64(upper, ^uint64(0))
+	upper = append(upper, make([]byte, gethCommon.HashLength)...)
+
+	iter, err := s.db.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	bySlot := make(map[uint64]map[gethCommon.Hash]*Constraint)
+	for iter.First(); iter.Valid(); iter.Next() {
+		slot, id, constraint, err := decodeConstraintEntry(iter.Key(), iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		if bySlot[slot] == nil {
+			bySlot[slot] = make(map[gethCommon.Hash]*Constraint)
+		}
+		bySlot[slot][id] = constraint
+	}
+	return bySlot, iter.Error()
+}
+
+func (s *pebbleConstraintStore) Prune(beforeSlot uint64) error {
+	lower := constraintStoreKey(0, gethCommon.Hash{})
+	upper := constraintStoreKey(beforeSlot, gethCommon.Hash{})
+	return s.db.DeleteRange(lower, upper, pebble.Sync)
+}
+
+func (s *pebbleConstraintStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *pebbleConstraintStore) scan(lower, upper []byte) (map[gethCommon.Hash]*Constraint, error) {
+	iter, err := s.db.NewIter(&pebble.IterOptions{LowerBound: lower, UpperBound: upper})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	found := make(map[gethCommon.Hash]*Constraint)
+	for iter.First(); iter.Valid(); iter.Next() {
+		_, id, constraint, err := decodeConstraintEntry(iter.Key(), iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		found[id] = constraint
+	}
+	return found, iter.Error()
+}
+
+func decodeConstraintEntry(key, value []byte) (uint64, gethCommon.Hash, *Constraint, error) {
+	if len(key) != 8+gethCommon.HashLength {
+		return 0, gethCommon.Hash{}, nil, fmt.Errorf("malformed constraint store key of length %d", len(key))
+	}
+	slot := binary.BigEndian.Uint64(key[:8])
+	id := gethCommon.BytesToHash(key[8:])
+
+	constraint := new(Constraint)
+	if err := json.Unmarshal(value, constraint); err != nil {
+		return 0, gethCommon.Hash{}, nil, err
+	}
+	return slot, id, constraint, nil
+}
+
+// memoryConstraintStore is a ConstraintStore kept entirely in memory, useful for tests
+// and for running without a configured disk-backed store.
+type memoryConstraintStore struct {
+	mu   sync.Mutex
+	data map[uint64]map[gethCommon.Hash]*Constraint
+}
+
+// NewMemoryConstraintStore creates a ConstraintStore with no durability guarantees.
+func NewMemoryConstraintStore() ConstraintStore {
+	return &memoryConstraintStore{data: make(map[uint64]map[gethCommon.Hash]*Constraint)}
+}
+
+func (s *memoryConstraintStore) PutBatch(slot uint64, constraints map[gethCommon.Hash]*Constraint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[slot] == nil {
+		s.data[slot] = make(map[gethCommon.Hash]*Constraint)
+	}
+	for id, constraint := range constraints {
+		s.data[slot][id] = constraint
+	}
+	return nil
+}
+
+func (s *memoryConstraintStore) Load(slot uint64) (map[gethCommon.Hash]*Constraint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[slot], nil
+}
+
+func (s *memoryConstraintStore) LoadFrom(fromSlot uint64) (map[uint64]map[gethCommon.Hash]*Constraint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bySlot := make(map[uint64]map[gethCommon.Hash]*Constraint)
+	for slot, constraints := range s.data {
+		if slot >= fromSlot {
+			bySlot[slot] = constraints
+		}
+	}
+	return bySlot, nil
+}
+
+func (s *memoryConstraintStore) Prune(beforeSlot uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for slot := range s.data {
+		if slot < beforeSlot {
+			delete(s.data, slot)
+		}
+	}
+	return nil
+}
+
+func (s *memoryConstraintStore) Close() error { return nil }
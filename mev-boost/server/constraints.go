@@ -1,12 +1,70 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	gethCommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	lru "github.com/hashicorp/golang-lru/v2"
 )
 
+// ConstraintKind discriminates between the different kinds of constraints
+// a proposer can place on a slot's block.
+type ConstraintKind uint8
+
+const (
+	// InclusionConstraintKind requires that Tx appears in the block, optionally at Index.
+	InclusionConstraintKind ConstraintKind = iota
+	// ExclusionConstraintKind forbids Exclusion.TxHash from appearing in the block.
+	ExclusionConstraintKind
+	// OrderingConstraintKind requires Ordering.Before to appear at a lower index than
+	// Ordering.After, if both are present in the block.
+	OrderingConstraintKind
+)
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case InclusionConstraintKind:
+		return "inclusion"
+	case ExclusionConstraintKind:
+		return "exclusion"
+	case OrderingConstraintKind:
+		return "ordering"
+	default:
+		return "unknown"
+	}
+}
+
+// ExclusionConstraint forbids a specific transaction from appearing in the slot's block,
+// e.g. to censor a known-malicious bundle the validator has pre-signed against.
+type ExclusionConstraint struct {
+	TxHash gethCommon.Hash `json:"tx_hash"`
+}
+
+// OrderingConstraint requires that Before appears at a lower index than After, if both
+// are present in the proposed block.
+type OrderingConstraint struct {
+	Before gethCommon.Hash `json:"before"`
+	After  gethCommon.Hash `json:"after"`
+}
+
+// Errors returned by Constraint.ValidateAgainst. They are typed so that
+// callers (e.g. the builder's block-assembly path) can tell an expired
+// conditional constraint apart from one that is simply unsatisfiable and
+// drop it from the cache instead of repeatedly trying to include it.
+var (
+	ErrConditionBlockRange     = errors.New("constraint: current block number outside of conditional window")
+	ErrConditionTimestampRange = errors.New("constraint: current timestamp outside of conditional window")
+	ErrConditionKnownAccount   = errors.New("constraint: known account predicate not satisfied")
+)
+
 type BatchedSignedConstraints = []*SignedConstraints
 
 type SignedConstraints struct {
@@ -21,8 +79,121 @@ type ConstraintsMessage struct {
 }
 
 type Constraint struct {
-	Tx    Transaction `json:"tx"`
-	Index *uint64     `json:"index"`
+	Kind ConstraintKind `json:"kind"`
+
+	// Inclusion fields, set when Kind == InclusionConstraintKind.
+	Tx    Transaction `json:"tx,omitempty"`
+	Index *uint64     `json:"index,omitempty"`
+
+	// Conditional mirrors the bor_sendRawTransactionConditional / EIP-4337
+	// convention for bundled transactions: the constraint is only binding
+	// while the window and known-account predicates it describes hold.
+	// Only meaningful for inclusion constraints.
+	Conditional *ConditionalOptions `json:"conditional,omitempty"`
+
+	// Exclusion is set when Kind == ExclusionConstraintKind.
+	Exclusion *ExclusionConstraint `json:"exclusion,omitempty"`
+
+	// Ordering is set when Kind == OrderingConstraintKind.
+	Ordering *OrderingConstraint `json:"ordering,omitempty"`
+}
+
+// ConditionalOptions describes the conditions under which a constraint is
+// still valid, following the bor_sendRawTransactionConditional convention.
+type ConditionalOptions struct {
+	KnownAccounts  map[gethCommon.Address]AccountPredicate `json:"knownAccounts,omitempty"`
+	BlockNumberMin *uint64                                 `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *uint64                                 `json:"blockNumberMax,omitempty"`
+	TimestampMin   *uint64                                 `json:"timestampMin,omitempty"`
+	TimestampMax   *uint64                                 `json:"timestampMax,omitempty"`
+}
+
+// AccountPredicate is either a single expected storage-root hash for the
+// account, or a map of storage slot to expected value. Exactly one of the
+// two forms is populated, matching the wire convention where the predicate
+// is encoded as either a hash string or an object of slot -> value.
+type AccountPredicate struct {
+	StorageRoot  *gethCommon.Hash
+	StorageSlots map[gethCommon.Hash]gethCommon.Hash
+}
+
+// MarshalJSON encodes the predicate in whichever form it was populated with.
+func (p AccountPredicate) MarshalJSON() ([]byte, error) {
+	if p.StorageRoot != nil {
+		return json.Marshal(p.StorageRoot)
+	}
+	return json.Marshal(p.StorageSlots)
+}
+
+// UnmarshalJSON accepts both the hash-form (a single storage-root hash) and
+// the slot-map form of the predicate, for wire compatibility with the 4337
+// convention.
+func (p *AccountPredicate) UnmarshalJSON(data []byte) error {
+	var hash gethCommon.Hash
+	if err := json.Unmarshal(data, &hash); err == nil {
+		p.StorageRoot = &hash
+		p.StorageSlots = nil
+		return nil
+	}
+
+	slots := make(map[gethCommon.Hash]gethCommon.Hash)
+	if err := json.Unmarshal(data, &slots); err != nil {
+		return fmt.Errorf("account predicate is neither a storage-root hash nor a slot map: %w", err)
+	}
+	p.StorageRoot = nil
+	p.StorageSlots = slots
+	return nil
+}
+
+// ValidateAgainst checks the constraint's conditional window and known-account
+// predicates, if any, against the given state and header. A nil Conditional
+// always validates successfully.
+func (c *Constraint) ValidateAgainst(state vm.StateDB, header *types.Header) error {
+	if c.Conditional == nil {
+		return nil
+	}
+	cond := c.Conditional
+
+	blockNumber := header.Number.Uint64()
+	if cond.BlockNumberMin != nil && blockNumber < *cond.BlockNumberMin {
+		return ErrConditionBlockRange
+	}
+	if cond.BlockNumberMax != nil && blockNumber > *cond.BlockNumberMax {
+		return ErrConditionBlockRange
+	}
+
+	if cond.TimestampMin != nil && header.Time < *cond.TimestampMin {
+		return ErrConditionTimestampRange
+	}
+	if cond.TimestampMax != nil && header.Time > *cond.TimestampMax {
+		return ErrConditionTimestampRange
+	}
+
+	for addr, predicate := range cond.KnownAccounts {
+		if predicate.StorageRoot != nil {
+			if state.GetStorageRoot(addr) != *predicate.StorageRoot {
+				return ErrConditionKnownAccount
+			}
+			continue
+		}
+		for slot, expected := range predicate.StorageSlots {
+			if state.GetState(addr, slot) != expected {
+				return ErrConditionKnownAccount
+			}
+		}
+	}
+
+	return nil
+}
+
+// expired reports whether the constraint's conditional window has already
+// passed for the given expected block number, so the cache can refuse to
+// hold onto constraints that can never be satisfied.
+func (c *Constraint) expired(expectedBlockNumber uint64) bool {
+	if c.Conditional == nil || c.Conditional.BlockNumberMax == nil {
+		return false
+	}
+	return expectedBlockNumber > *c.Conditional.BlockNumberMax
 }
 
 func (s *SignedConstraints) String() string {
@@ -37,82 +208,336 @@ func (c *Constraint) String() string {
 	return JSONStringify(c)
 }
 
-// ConstraintCache is a cache for constraints.
+// ConstraintCache is a two-tier cache for constraints: an in-memory LRU fronting a
+// pluggable, durable ConstraintStore. Writes go to both tiers synchronously so a relay
+// restart can rehydrate everything that hasn't aged out, and a slot falling out of the
+// LRU no longer means losing it - eviction just drops it from the hot tier.
 type ConstraintCache struct {
-	// map of slots to all constraints for that slot
+	// map of slots to all constraints for that slot, keyed by a stable constraint ID
+	// (rather than tx hash) so exclusion and ordering constraints, which have no tx
+	// of their own to key on, are addressable too.
 	constraints *lru.Cache[uint64, map[gethCommon.Hash]*Constraint]
+	store       ConstraintStore
+
+	// txIndex maps an inclusion constraint's tx hash directly to the (slot, constraint ID)
+	// it lives under, so FindTransactionByHash is O(1) instead of scanning every cached
+	// slot - the previous linear scan is a hot path during block building.
+	mu      sync.Mutex
+	txIndex map[gethCommon.Hash]constraintLocation
+	onEvict func(slot uint64, constraints map[gethCommon.Hash]*Constraint)
 }
 
-// NewConstraintCache creates a new constraint cache.
-// cap is the maximum number of slots to store constraints for.
-func NewConstraintCache(cap int) *ConstraintCache {
-	constraints, _ := lru.New[uint64, map[gethCommon.Hash]*Constraint](cap)
-	return &ConstraintCache{
-		constraints: constraints,
+// constraintLocation pinpoints a single constraint within the cache.
+type constraintLocation struct {
+	slot uint64
+	id   gethCommon.Hash
+}
+
+// NewConstraintCache creates a new constraint cache backed by store.
+// cap is the maximum number of slots to keep hot in memory. On construction, every
+// constraint persisted in store for a slot >= currentSlot is loaded back into the LRU.
+func NewConstraintCache(cap int, store ConstraintStore, currentSlot uint64) (*ConstraintCache, error) {
+	c := &ConstraintCache{store: store, txIndex: make(map[gethCommon.Hash]constraintLocation)}
+	constraints, err := lru.NewWithEvict(cap, c.handleEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.constraints = constraints
+
+	bySlot, err := store.LoadFrom(currentSlot)
+	if err != nil {
+		return nil, fmt.Errorf("rehydrating constraint cache: %w", err)
+	}
+	for slot, m := range bySlot {
+		c.constraints.Add(slot, m)
+		c.indexSlot(slot, m)
 	}
+
+	return c, nil
 }
 
-// AddInclusionConstraint adds an inclusion constraint to the cache at the given slot for the given transaction.
-func (c *ConstraintCache) AddInclusionConstraint(slot uint64, tx Transaction, index *uint64) error {
-	if _, exists := c.constraints.Get(slot); !exists {
-		c.constraints.Add(slot, make(map[gethCommon.Hash]*Constraint))
+// indexSlot (re)populates the txIndex entries for every inclusion constraint in m. It is
+// used on rehydrate, where constraints are loaded in bulk and may be keyed by either the
+// tx hash (the AddInclusionConstraint(s) path) or a constraint ID (the AddSignedConstraints
+// path).
+func (c *ConstraintCache) indexSlot(slot uint64, m map[gethCommon.Hash]*Constraint) {
+	for id, constraint := range m {
+		if constraint.Kind != InclusionConstraintKind {
+			continue
+		}
+		parsedTx := new(types.Transaction)
+		if err := parsedTx.UnmarshalBinary(constraint.Tx); err != nil {
+			continue
+		}
+		c.indexInclusion(parsedTx.Hash(), slot, id)
 	}
+}
+
+// indexInclusion records that the inclusion constraint identified by id, for txHash, lives
+// in slot, so FindTransactionByHash can resolve it in O(1).
+func (c *ConstraintCache) indexInclusion(txHash gethCommon.Hash, slot uint64, id gethCommon.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.txIndex[txHash] = constraintLocation{slot: slot, id: id}
+}
+
+// OnEvict registers a callback invoked whenever a slot's constraints age out of the
+// in-memory LRU tier, e.g. to emit metrics or unlock bundles that were held pending
+// those constraints. Only one callback may be registered at a time.
+func (c *ConstraintCache) OnEvict(fn func(slot uint64, constraints map[gethCommon.Hash]*Constraint)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
 
-	// parse transaction to get its hash and store it in the cache
-	// for constant time lookup later
+func (c *ConstraintCache) handleEvict(slot uint64, constraints map[gethCommon.Hash]*Constraint) {
+	c.mu.Lock()
+	for txHash, loc := range c.txIndex {
+		if loc.slot == slot {
+			delete(c.txIndex, txHash)
+		}
+	}
+	fn := c.onEvict
+	c.mu.Unlock()
+	if fn != nil {
+		fn(slot, constraints)
+	}
+}
+
+// Prune deterministically removes constraints for slots before beforeSlot from both the
+// in-memory and durable tiers. Unlike LRU eviction, this is the mechanism that should be
+// used to drop constraints once their slot has finalized, since capacity-based eviction
+// alone is unsafe when a validator signs many constraints for a distant slot.
+func (c *ConstraintCache) Prune(beforeSlot uint64) error {
+	for _, slot := range c.constraints.Keys() {
+		if slot < beforeSlot {
+			c.constraints.Remove(slot)
+		}
+	}
+
+	c.mu.Lock()
+	for txHash, loc := range c.txIndex {
+		if loc.slot < beforeSlot {
+			delete(c.txIndex, txHash)
+		}
+	}
+	c.mu.Unlock()
+
+	return c.store.Prune(beforeSlot)
+}
+
+// persist writes the slot's constraint map to the durable tier, batched per slot.
+func (c *ConstraintCache) persist(slot uint64, constraints map[gethCommon.Hash]*Constraint) error {
+	return c.store.PutBatch(slot, constraints)
+}
+
+// constraintID derives a stable ID for the innerIndex-th constraint of a signed message,
+// hashing the message itself together with the inner index so that constraints from
+// distinct messages (or distinct positions within the same message) never collide.
+func constraintID(message *ConstraintsMessage, innerIndex int) gethCommon.Hash {
+	return crypto.Keccak256Hash([]byte(message.String()), []byte(strconv.Itoa(innerIndex)))
+}
+
+// AddInclusionConstraint adds an inclusion constraint to the cache at the given slot for the given transaction.
+// expectedBlockNumber is the block number the target slot is expected to produce; if the constraint carries a
+// conditional window that has already passed for that block number, it is rejected rather than cached.
+func (c *ConstraintCache) AddInclusionConstraint(slot uint64, tx Transaction, index *uint64, conditional *ConditionalOptions, expectedBlockNumber uint64) error {
+	// parse transaction to get its hash; it both seeds the constraint ID and is
+	// reused by FindTransactionByHash for constant time lookup later
 	parsedTx := new(types.Transaction)
 	err := parsedTx.UnmarshalBinary(tx)
 	if err != nil {
 		return err
 	}
 
-	m, _ := c.constraints.Get(slot)
-	m[parsedTx.Hash()] = &Constraint{
-		Tx:    tx,
-		Index: index,
+	constraint := &Constraint{
+		Kind:        InclusionConstraintKind,
+		Tx:          tx,
+		Index:       index,
+		Conditional: conditional,
+	}
+	if constraint.expired(expectedBlockNumber) {
+		return ErrConditionBlockRange
 	}
 
-	return nil
-}
-
-// AddInclusionConstraints adds multiple inclusion constraints to the cache at the given slot
-func (c *ConstraintCache) AddInclusionConstraints(slot uint64, constraints []*Constraint) error {
 	if _, exists := c.constraints.Get(slot); !exists {
 		c.constraints.Add(slot, make(map[gethCommon.Hash]*Constraint))
 	}
 
+	// The per-slot map returned by the LRU is a plain Go map shared by every caller
+	// holding a reference to it, so mutating it - and the txIndex entry alongside it -
+	// must happen under c.mu, not just the txIndex bookkeeping in indexInclusion. The
+	// lookup-or-create Add above stays outside the lock since it can synchronously
+	// trigger handleEvict, which takes c.mu itself.
+	c.mu.Lock()
 	m, _ := c.constraints.Get(slot)
+	m[parsedTx.Hash()] = constraint
+	c.txIndex[parsedTx.Hash()] = constraintLocation{slot: slot, id: parsedTx.Hash()}
+	c.mu.Unlock()
+
+	return c.persist(slot, m)
+}
+
+// AddInclusionConstraints adds multiple inclusion constraints to the cache at the given
+// slot. expectedBlockNumber is the block number the target slot is expected to produce,
+// same as in AddInclusionConstraint; if any constraint in the batch carries a conditional
+// window that has already passed for that block number, the whole batch is rejected rather
+// than partially applied.
+func (c *ConstraintCache) AddInclusionConstraints(slot uint64, constraints []*Constraint, expectedBlockNumber uint64) error {
 	for _, constraint := range constraints {
+		constraint.Kind = InclusionConstraintKind
+		if constraint.expired(expectedBlockNumber) {
+			return ErrConditionBlockRange
+		}
+	}
+
+	// Parse every transaction up front so a malformed entry fails before anything is
+	// mutated, then apply the whole batch under c.mu in one critical section - see
+	// AddInclusionConstraint for why the per-slot map needs the lock too.
+	parsedTxs := make([]*types.Transaction, len(constraints))
+	for i, constraint := range constraints {
 		parsedTx := new(types.Transaction)
-		err := parsedTx.UnmarshalBinary(constraint.Tx)
-		if err != nil {
+		if err := parsedTx.UnmarshalBinary(constraint.Tx); err != nil {
 			return err
 		}
-		m[parsedTx.Hash()] = constraint
+		parsedTxs[i] = parsedTx
 	}
 
-	return nil
+	if _, exists := c.constraints.Get(slot); !exists {
+		c.constraints.Add(slot, make(map[gethCommon.Hash]*Constraint))
+	}
+
+	c.mu.Lock()
+	m, _ := c.constraints.Get(slot)
+	for i, constraint := range constraints {
+		m[parsedTxs[i].Hash()] = constraint
+		c.txIndex[parsedTxs[i].Hash()] = constraintLocation{slot: slot, id: parsedTxs[i].Hash()}
+	}
+	c.mu.Unlock()
+
+	return c.persist(slot, m)
 }
 
-// Get gets the constraints at the given slot.
+// AddSignedConstraints ingests a relay-signed constraints message, keying every
+// inclusion, exclusion and ordering constraint it carries by its stable constraint ID.
+func (c *ConstraintCache) AddSignedConstraints(signed *SignedConstraints) error {
+	slot := signed.Message.Slot
+
+	if _, exists := c.constraints.Get(slot); !exists {
+		c.constraints.Add(slot, make(map[gethCommon.Hash]*Constraint))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, _ := c.constraints.Get(slot)
+	for i, constraint := range signed.Message.Constraints {
+		id := constraintID(&signed.Message, i)
+		if constraint.Kind == InclusionConstraintKind && len(constraint.Tx) > 0 {
+			parsedTx := new(types.Transaction)
+			if err := parsedTx.UnmarshalBinary(constraint.Tx); err != nil {
+				return err
+			}
+			c.txIndex[parsedTx.Hash()] = constraintLocation{slot: slot, id: id}
+		}
+		m[id] = constraint
+	}
+
+	return c.persist(slot, m)
+}
+
+// Get gets the constraints at the given slot, as a snapshot copy safe to read without
+// holding c.mu - the per-slot map itself is shared with Add*, which mutates it under c.mu.
 func (c *ConstraintCache) Get(slot uint64) (map[gethCommon.Hash]*Constraint, bool) {
-	return c.constraints.Get(slot)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m, exists := c.constraints.Get(slot)
+	if !exists {
+		return nil, false
+	}
+	snapshot := make(map[gethCommon.Hash]*Constraint, len(m))
+	for k, v := range m {
+		snapshot[k] = v
+	}
+	return snapshot, true
 }
 
-// FindTransactionByHash finds the constraint for the given transaction hash and returns it.
+// FindTransactionByHash finds the inclusion constraint for the given transaction hash and returns it.
+// Exclusion and ordering constraints are never matched, since they have no single tx to associate
+// the hash with.
 func (c *ConstraintCache) FindTransactionByHash(txHash gethCommon.Hash) (*Constraint, bool) {
-	for _, hashToConstraint := range c.constraints.Values() {
-		if constraint, exists := hashToConstraint[txHash]; exists {
-			return constraint, true
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	loc, exists := c.txIndex[txHash]
+	if !exists {
+		return nil, false
+	}
+
+	hashToConstraint, exists := c.constraints.Get(loc.slot)
+	if !exists {
+		return nil, false
+	}
+	constraint, exists := hashToConstraint[loc.id]
+	if !exists || constraint.Kind != InclusionConstraintKind {
+		return nil, false
+	}
+	return constraint, true
+}
+
+// FindExclusions returns all exclusion constraints registered for the given slot.
+func (c *ConstraintCache) FindExclusions(slot uint64) []*Constraint {
+	return c.findByKind(slot, ExclusionConstraintKind)
+}
+
+// FindOrderings returns all ordering constraints registered for the given slot.
+func (c *ConstraintCache) FindOrderings(slot uint64) []*Constraint {
+	return c.findByKind(slot, OrderingConstraintKind)
+}
+
+// findByKind holds c.mu for the duration of the scan, since the per-slot map it reads from
+// is the same one Add* mutates directly under c.mu.
+func (c *ConstraintCache) findByKind(slot uint64, kind ConstraintKind) []*Constraint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hashToConstraint, exists := c.constraints.Get(slot)
+	if !exists {
+		return nil
+	}
+	var found []*Constraint
+	for _, constraint := range hashToConstraint {
+		if constraint.Kind == kind {
+			found = append(found, constraint)
 		}
 	}
-	return nil, false
+	return found
 }
 
-type (
-	HashToConstraintDecoded = map[gethCommon.Hash]*ConstraintDecoded
-	ConstraintDecoded       struct {
-		Index *uint64
-		Tx    *types.Transaction
+// Validate walks the proposed block's transactions and reports the first violated
+// exclusion or ordering constraint registered for the slot. Inclusion constraints are
+// validated separately by the builder's packing logic, which is in a better position to
+// report missing/misplaced txs.
+func (c *ConstraintCache) Validate(slot uint64, txs types.Transactions) error {
+	indexOf := make(map[gethCommon.Hash]int, len(txs))
+	for i, tx := range txs {
+		indexOf[tx.Hash()] = i
 	}
-)
+
+	for _, constraint := range c.FindExclusions(slot) {
+		if _, present := indexOf[constraint.Exclusion.TxHash]; present {
+			return fmt.Errorf("exclusion constraint violated: tx %s must not appear in slot %d", constraint.Exclusion.TxHash, slot)
+		}
+	}
+
+	for _, constraint := range c.FindOrderings(slot) {
+		beforeIdx, beforePresent := indexOf[constraint.Ordering.Before]
+		afterIdx, afterPresent := indexOf[constraint.Ordering.After]
+		if beforePresent && afterPresent && beforeIdx >= afterIdx {
+			return fmt.Errorf("ordering constraint violated: tx %s must appear before tx %s in slot %d", constraint.Ordering.Before, constraint.Ordering.After, slot)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,497 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	gethCommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	benchSlots            = 32
+	benchConstraintsPerTx = 10_000 / benchSlots
+)
+
+// newBenchConstraintCache seeds a cache with ~10k constraints spread across 32 slots, to
+// exercise FindTransactionByHash the way it is hit during block building.
+func newBenchConstraintCache(b *testing.B) (*ConstraintCache, []gethCommon.Hash) {
+	b.Helper()
+
+	cache, err := NewConstraintCache(benchSlots, NewMemoryConstraintStore(), 0)
+	if err != nil {
+		b.Fatalf("NewConstraintCache: %v", err)
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+
+	var hashes []gethCommon.Hash
+	for slot := uint64(0); slot < benchSlots; slot++ {
+		for i := 0; i < benchConstraintsPerTx; i++ {
+			tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    uint64(i),
+				To:       &gethCommon.Address{},
+				Value:    big.NewInt(0),
+				Gas:      21000,
+				GasPrice: big.NewInt(1),
+			})
+			if err != nil {
+				b.Fatalf("SignNewTx: %v", err)
+			}
+			raw, err := tx.MarshalBinary()
+			if err != nil {
+				b.Fatalf("MarshalBinary: %v", err)
+			}
+			if err := cache.AddInclusionConstraint(slot, raw, nil, nil, slot); err != nil {
+				b.Fatalf("AddInclusionConstraint: %v", err)
+			}
+			hashes = append(hashes, tx.Hash())
+		}
+	}
+
+	return cache, hashes
+}
+
+// linearScanFindTransactionByHash reproduces the O(slots) scan FindTransactionByHash
+// replaced, for a before/after comparison against BenchmarkFindTransactionByHash.
+func linearScanFindTransactionByHash(c *ConstraintCache, txHash gethCommon.Hash) (*Constraint, bool) {
+	for _, slot := range c.constraints.Keys() {
+		hashToConstraint, ok := c.constraints.Peek(slot)
+		if !ok {
+			continue
+		}
+		if constraint, exists := hashToConstraint[txHash]; exists && constraint.Kind == InclusionConstraintKind {
+			return constraint, true
+		}
+	}
+	return nil, false
+}
+
+// BenchmarkFindTransactionByHashLinearScan measures the previous O(slots) linear scan
+// against the same ~10k constraints as BenchmarkFindTransactionByHash, to quantify the
+// improvement the txIndex gives FindTransactionByHash.
+func BenchmarkFindTransactionByHashLinearScan(b *testing.B) {
+	cache, hashes := newBenchConstraintCache(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := linearScanFindTransactionByHash(cache, hashes[i%len(hashes)]); !ok {
+			b.Fatalf("expected to find constraint for tx %s", hashes[i%len(hashes)])
+		}
+	}
+}
+
+// BenchmarkFindTransactionByHash measures the indexed lookup against ~10k constraints
+// spread across 32 slots, the hot path the previous O(slots) linear scan bottlenecked on.
+func BenchmarkFindTransactionByHash(b *testing.B) {
+	cache, hashes := newBenchConstraintCache(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := cache.FindTransactionByHash(hashes[i%len(hashes)]); !ok {
+			b.Fatalf("expected to find constraint for tx %s", hashes[i%len(hashes)])
+		}
+	}
+}
+
+func TestConstraintCachePruneRemovesFromBothTiers(t *testing.T) {
+	store := NewMemoryConstraintStore()
+	cache, err := NewConstraintCache(32, store, 0)
+	if err != nil {
+		t.Fatalf("NewConstraintCache: %v", err)
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &gethCommon.Address{},
+		Value:    big.NewInt(0),
+		Gas:      21000,
+		GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("SignNewTx: %v", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	if err := cache.AddInclusionConstraint(5, raw, nil, nil, 5); err != nil {
+		t.Fatalf("AddInclusionConstraint: %v", err)
+	}
+	if _, ok := cache.FindTransactionByHash(tx.Hash()); !ok {
+		t.Fatalf("expected constraint to be present before pruning")
+	}
+
+	if err := cache.Prune(10); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+
+	if _, ok := cache.FindTransactionByHash(tx.Hash()); ok {
+		t.Fatalf("expected constraint to be pruned from the in-memory tier")
+	}
+	if persisted, err := store.Load(5); err != nil || len(persisted) != 0 {
+		t.Fatalf("expected constraint to be pruned from the durable tier, got %v (err %v)", persisted, err)
+	}
+}
+
+func TestConstraintCacheOnEvict(t *testing.T) {
+	cache, err := NewConstraintCache(1, NewMemoryConstraintStore(), 0)
+	if err != nil {
+		t.Fatalf("NewConstraintCache: %v", err)
+	}
+
+	evicted := make(chan uint64, 1)
+	cache.OnEvict(func(slot uint64, _ map[gethCommon.Hash]*Constraint) {
+		evicted <- slot
+	})
+
+	if err := cache.AddInclusionConstraint(1, []byte{}, nil, nil, 1); err == nil {
+		t.Fatalf("expected malformed tx to be rejected")
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+	for _, slot := range []uint64{1, 2} {
+		tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+			Nonce:    slot,
+			To:       &gethCommon.Address{},
+			Value:    big.NewInt(0),
+			Gas:      21000,
+			GasPrice: big.NewInt(1),
+		})
+		if err != nil {
+			t.Fatalf("SignNewTx: %v", err)
+		}
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary: %v", err)
+		}
+		if err := cache.AddInclusionConstraint(slot, raw, nil, nil, slot); err != nil {
+			t.Fatalf("AddInclusionConstraint: %v", err)
+		}
+	}
+
+	select {
+	case slot := <-evicted:
+		if slot != 1 {
+			t.Fatalf("expected slot 1 to be evicted first, got %d", slot)
+		}
+	default:
+		t.Fatalf("expected OnEvict to fire once the cache exceeded capacity")
+	}
+}
+
+// fakeValidateState implements just enough of vm.StateDB for ValidateAgainst's
+// known-account predicates; every other method panics if called.
+type fakeValidateState struct {
+	vm.StateDB
+	storageRoots map[gethCommon.Address]gethCommon.Hash
+	storageSlots map[gethCommon.Address]map[gethCommon.Hash]gethCommon.Hash
+}
+
+func (s *fakeValidateState) GetStorageRoot(addr gethCommon.Address) gethCommon.Hash {
+	return s.storageRoots[addr]
+}
+
+func (s *fakeValidateState) GetState(addr gethCommon.Address, slot gethCommon.Hash) gethCommon.Hash {
+	return s.storageSlots[addr][slot]
+}
+
+func TestConstraintValidateAgainstNilConditional(t *testing.T) {
+	c := &Constraint{Kind: InclusionConstraintKind}
+	header := &types.Header{Number: big.NewInt(10), Time: 100}
+	if err := c.ValidateAgainst(&fakeValidateState{}, header); err != nil {
+		t.Fatalf("expected a nil conditional to always validate, got %v", err)
+	}
+}
+
+func TestConstraintValidateAgainstBlockRange(t *testing.T) {
+	min, max := uint64(10), uint64(20)
+	c := &Constraint{Conditional: &ConditionalOptions{BlockNumberMin: &min, BlockNumberMax: &max}}
+	state := &fakeValidateState{}
+
+	if err := c.ValidateAgainst(state, &types.Header{Number: big.NewInt(15)}); err != nil {
+		t.Fatalf("expected block 15 to be within [10, 20], got %v", err)
+	}
+	if err := c.ValidateAgainst(state, &types.Header{Number: big.NewInt(9)}); !errors.Is(err, ErrConditionBlockRange) {
+		t.Fatalf("expected ErrConditionBlockRange below the window, got %v", err)
+	}
+	if err := c.ValidateAgainst(state, &types.Header{Number: big.NewInt(21)}); !errors.Is(err, ErrConditionBlockRange) {
+		t.Fatalf("expected ErrConditionBlockRange above the window, got %v", err)
+	}
+}
+
+func TestConstraintValidateAgainstTimestampRange(t *testing.T) {
+	min, max := uint64(1000), uint64(2000)
+	c := &Constraint{Conditional: &ConditionalOptions{TimestampMin: &min, TimestampMax: &max}}
+	state := &fakeValidateState{}
+
+	if err := c.ValidateAgainst(state, &types.Header{Number: big.NewInt(1), Time: 1500}); err != nil {
+		t.Fatalf("expected timestamp 1500 to be within [1000, 2000], got %v", err)
+	}
+	if err := c.ValidateAgainst(state, &types.Header{Number: big.NewInt(1), Time: 999}); !errors.Is(err, ErrConditionTimestampRange) {
+		t.Fatalf("expected ErrConditionTimestampRange below the window, got %v", err)
+	}
+	if err := c.ValidateAgainst(state, &types.Header{Number: big.NewInt(1), Time: 2001}); !errors.Is(err, ErrConditionTimestampRange) {
+		t.Fatalf("expected ErrConditionTimestampRange above the window, got %v", err)
+	}
+}
+
+func TestConstraintValidateAgainstKnownAccountStorageRoot(t *testing.T) {
+	addr := gethCommon.HexToAddress("0x1")
+	root := gethCommon.HexToHash("0xabc")
+	c := &Constraint{Conditional: &ConditionalOptions{
+		KnownAccounts: map[gethCommon.Address]AccountPredicate{addr: {StorageRoot: &root}},
+	}}
+	header := &types.Header{Number: big.NewInt(1)}
+
+	matching := &fakeValidateState{storageRoots: map[gethCommon.Address]gethCommon.Hash{addr: root}}
+	if err := c.ValidateAgainst(matching, header); err != nil {
+		t.Fatalf("expected matching storage root to validate, got %v", err)
+	}
+
+	mismatched := &fakeValidateState{storageRoots: map[gethCommon.Address]gethCommon.Hash{addr: gethCommon.HexToHash("0xdead")}}
+	if err := c.ValidateAgainst(mismatched, header); !errors.Is(err, ErrConditionKnownAccount) {
+		t.Fatalf("expected ErrConditionKnownAccount on mismatch, got %v", err)
+	}
+}
+
+func TestConstraintValidateAgainstKnownAccountStorageSlots(t *testing.T) {
+	addr := gethCommon.HexToAddress("0x2")
+	slot := gethCommon.HexToHash("0x1")
+	expected := gethCommon.HexToHash("0x42")
+	c := &Constraint{Conditional: &ConditionalOptions{
+		KnownAccounts: map[gethCommon.Address]AccountPredicate{
+			addr: {StorageSlots: map[gethCommon.Hash]gethCommon.Hash{slot: expected}},
+		},
+	}}
+	header := &types.Header{Number: big.NewInt(1)}
+
+	matching := &fakeValidateState{storageSlots: map[gethCommon.Address]map[gethCommon.Hash]gethCommon.Hash{addr: {slot: expected}}}
+	if err := c.ValidateAgainst(matching, header); err != nil {
+		t.Fatalf("expected matching storage slot to validate, got %v", err)
+	}
+
+	mismatched := &fakeValidateState{storageSlots: map[gethCommon.Address]map[gethCommon.Hash]gethCommon.Hash{addr: {slot: gethCommon.HexToHash("0x43")}}}
+	if err := c.ValidateAgainst(mismatched, header); !errors.Is(err, ErrConditionKnownAccount) {
+		t.Fatalf("expected ErrConditionKnownAccount on mismatch, got %v", err)
+	}
+}
+
+func TestAccountPredicateJSONRoundTrip(t *testing.T) {
+	root := gethCommon.HexToHash("0xabc")
+	hashForm := AccountPredicate{StorageRoot: &root}
+	data, err := json.Marshal(hashForm)
+	if err != nil {
+		t.Fatalf("Marshal (hash form): %v", err)
+	}
+	var decodedHash AccountPredicate
+	if err := json.Unmarshal(data, &decodedHash); err != nil {
+		t.Fatalf("Unmarshal (hash form): %v", err)
+	}
+	if decodedHash.StorageRoot == nil || *decodedHash.StorageRoot != root {
+		t.Fatalf("expected storage root %s to round-trip, got %+v", root, decodedHash)
+	}
+	if decodedHash.StorageSlots != nil {
+		t.Fatalf("expected hash-form decode to leave StorageSlots nil, got %v", decodedHash.StorageSlots)
+	}
+
+	slotForm := AccountPredicate{StorageSlots: map[gethCommon.Hash]gethCommon.Hash{
+		gethCommon.HexToHash("0x1"): gethCommon.HexToHash("0x2"),
+	}}
+	data, err = json.Marshal(slotForm)
+	if err != nil {
+		t.Fatalf("Marshal (slot form): %v", err)
+	}
+	var decodedSlots AccountPredicate
+	if err := json.Unmarshal(data, &decodedSlots); err != nil {
+		t.Fatalf("Unmarshal (slot form): %v", err)
+	}
+	if decodedSlots.StorageRoot != nil {
+		t.Fatalf("expected slot-form decode to leave StorageRoot nil, got %v", decodedSlots.StorageRoot)
+	}
+	if len(decodedSlots.StorageSlots) != 1 || decodedSlots.StorageSlots[gethCommon.HexToHash("0x1")] != gethCommon.HexToHash("0x2") {
+		t.Fatalf("expected storage slots to round-trip, got %v", decodedSlots.StorageSlots)
+	}
+}
+
+func TestAddInclusionConstraintsRejectsExpiredWindow(t *testing.T) {
+	cache, err := NewConstraintCache(32, NewMemoryConstraintStore(), 0)
+	if err != nil {
+		t.Fatalf("NewConstraintCache: %v", err)
+	}
+
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce: 0, To: &gethCommon.Address{}, Value: big.NewInt(0), Gas: 21000, GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("SignNewTx: %v", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	maxBlock := uint64(5)
+	constraint := &Constraint{Tx: raw, Conditional: &ConditionalOptions{BlockNumberMax: &maxBlock}}
+
+	if err := cache.AddInclusionConstraints(1, []*Constraint{constraint}, 10); !errors.Is(err, ErrConditionBlockRange) {
+		t.Fatalf("expected ErrConditionBlockRange for an already-expired window, got %v", err)
+	}
+	if _, ok := cache.FindTransactionByHash(tx.Hash()); ok {
+		t.Fatalf("expected the expired constraint to be rejected rather than cached")
+	}
+}
+
+// newValidateTestTx returns a signed legacy tx distinguished only by nonce, for tests that
+// just need distinct, hashable transactions to exercise Validate's exclusion/ordering
+// checks against.
+func newValidateTestTx(t *testing.T, nonce uint64) *types.Transaction {
+	t.Helper()
+	signer := types.LatestSignerForChainID(big.NewInt(1))
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce: nonce, To: &gethCommon.Address{}, Value: big.NewInt(0), Gas: 21000, GasPrice: big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("SignNewTx: %v", err)
+	}
+	return tx
+}
+
+func TestConstraintCacheValidate(t *testing.T) {
+	const slot = uint64(3)
+
+	txA := newValidateTestTx(t, 0)
+	txB := newValidateTestTx(t, 1)
+
+	tests := []struct {
+		name    string
+		kind    ConstraintKind
+		excl    *ExclusionConstraint
+		order   *OrderingConstraint
+		txs     types.Transactions
+		wantErr bool
+	}{
+		{
+			name:    "exclusion constraint hit",
+			kind:    ExclusionConstraintKind,
+			excl:    &ExclusionConstraint{TxHash: txA.Hash()},
+			txs:     types.Transactions{txA, txB},
+			wantErr: true,
+		},
+		{
+			name:    "exclusion constraint absent from block",
+			kind:    ExclusionConstraintKind,
+			excl:    &ExclusionConstraint{TxHash: txA.Hash()},
+			txs:     types.Transactions{txB},
+			wantErr: false,
+		},
+		{
+			name:    "ordering constraint violated",
+			kind:    OrderingConstraintKind,
+			order:   &OrderingConstraint{Before: txB.Hash(), After: txA.Hash()},
+			txs:     types.Transactions{txA, txB},
+			wantErr: true,
+		},
+		{
+			name:    "ordering constraint satisfied",
+			kind:    OrderingConstraintKind,
+			order:   &OrderingConstraint{Before: txA.Hash(), After: txB.Hash()},
+			txs:     types.Transactions{txA, txB},
+			wantErr: false,
+		},
+		{
+			name:    "ordering constraint with one side absent from block",
+			kind:    OrderingConstraintKind,
+			order:   &OrderingConstraint{Before: txB.Hash(), After: txA.Hash()},
+			txs:     types.Transactions{txA},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := NewConstraintCache(32, NewMemoryConstraintStore(), 0)
+			if err != nil {
+				t.Fatalf("NewConstraintCache: %v", err)
+			}
+			cache.constraints.Add(slot, map[gethCommon.Hash]*Constraint{
+				gethCommon.HexToHash("0x1"): {Kind: tt.kind, Exclusion: tt.excl, Ordering: tt.order},
+			})
+
+			err = cache.Validate(slot, tt.txs)
+			if tt.wantErr && err == nil {
+				t.Fatalf("expected Validate to reject the block, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected Validate to accept the block, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAddSignedConstraints(t *testing.T) {
+	cache, err := NewConstraintCache(32, NewMemoryConstraintStore(), 0)
+	if err != nil {
+		t.Fatalf("NewConstraintCache: %v", err)
+	}
+
+	tx := newValidateTestTx(t, 0)
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	const slot = uint64(9)
+	exclusion := &ExclusionConstraint{TxHash: gethCommon.HexToHash("0xbad")}
+	ordering := &OrderingConstraint{Before: gethCommon.HexToHash("0x1"), After: gethCommon.HexToHash("0x2")}
+	signed := &SignedConstraints{
+		Message: ConstraintsMessage{
+			ValidatorIndex: 1,
+			Slot:           slot,
+			Constraints: []*Constraint{
+				{Kind: InclusionConstraintKind, Tx: raw},
+				{Kind: ExclusionConstraintKind, Exclusion: exclusion},
+				{Kind: OrderingConstraintKind, Ordering: ordering},
+			},
+		},
+	}
+
+	if err := cache.AddSignedConstraints(signed); err != nil {
+		t.Fatalf("AddSignedConstraints: %v", err)
+	}
+
+	constraint, ok := cache.FindTransactionByHash(tx.Hash())
+	if !ok {
+		t.Fatalf("expected the inclusion constraint to be indexed by tx hash")
+	}
+	if constraint.Kind != InclusionConstraintKind {
+		t.Fatalf("expected an inclusion constraint, got %v", constraint.Kind)
+	}
+
+	exclusions := cache.FindExclusions(slot)
+	if len(exclusions) != 1 || exclusions[0].Exclusion.TxHash != exclusion.TxHash {
+		t.Fatalf("expected exactly the exclusion constraint to be registered, got %+v", exclusions)
+	}
+
+	orderings := cache.FindOrderings(slot)
+	if len(orderings) != 1 || orderings[0].Ordering.Before != ordering.Before || orderings[0].Ordering.After != ordering.After {
+		t.Fatalf("expected exactly the ordering constraint to be registered, got %+v", orderings)
+	}
+
+	all, ok := cache.Get(slot)
+	if !ok || len(all) != 3 {
+		t.Fatalf("expected all 3 constraints to be stored under the slot, got %+v", all)
+	}
+}